@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+// Account states. A suspended account can't log in or use its tokens until
+// the suspension ends. A shadow-banned account works as usual, but nobody
+// else sees its chirps or is notified of what it does.
+const (
+	accountActive       = "active"
+	accountSuspended    = "suspended"
+	accountShadowBanned = "shadow_banned"
+)
+
+func isSuspended(user database.User) bool {
+	return user.SuspendedUntil != nil && user.SuspendedUntil.After(time.Now())
+}
+
+func accountState(user database.User) string {
+	switch {
+	case isSuspended(user):
+		return accountSuspended
+	case user.ShadowBannedAt != nil:
+		return accountShadowBanned
+	default:
+		return accountActive
+	}
+}
+
+// checkNotSuspended answers 403 and returns false if user may not log in.
+// It is only called once the password is known to be right, so it doesn't
+// reveal anything about an account to someone guessing.
+func checkNotSuspended(w http.ResponseWriter, user database.User) bool {
+	if isSuspended(user) {
+		sendErrorResponse(w, http.StatusForbidden, "Account suspended until "+user.SuspendedUntil.Format(time.RFC3339))
+		return false
+	}
+	return true
+}
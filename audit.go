@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	auditLoginLockout       = "login_lockout"
+	auditRoleChange         = "role_change"
+	auditReportResolved     = "report_resolved"
+	auditAccountStateChange = "account_state_change"
+)
+
+// recordAuditEvent stores a security-relevant event. details is marshalled
+// to JSON.
+func recordAuditEvent(ctx context.Context, q *database.Queries, eventType string, userID uuid.NullUUID, ip string, details any) error {
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return q.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		Type:    eventType,
+		UserID:  userID,
+		Ip:      ip,
+		Details: data,
+	})
+}
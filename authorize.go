@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+// Scopes limit what a personal access token can do. Access tokens (JWTs)
+// from a login carry no scopes and can do everything the user can.
+const (
+	scopeChirpsRead         = "chirps:read"
+	scopeChirpsWrite        = "chirps:write"
+	scopeFollowsWrite       = "follows:write"
+	scopeNotificationsRead  = "notifications:read"
+	scopeNotificationsWrite = "notifications:write"
+	scopeProfileWrite       = "profile:write"
+
+	// scopeLoginOnly marks endpoints that manage the account itself, such as
+	// sessions, 2FA and tokens. Personal access tokens can't use them.
+	scopeLoginOnly = ""
+)
+
+var allScopes = []string{
+	scopeChirpsRead,
+	scopeChirpsWrite,
+	scopeFollowsWrite,
+	scopeNotificationsRead,
+	scopeNotificationsWrite,
+	scopeProfileWrite,
+}
+
+// Roles grant access to the /admin endpoints. Each role can do everything
+// the roles before it can.
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+func validRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+const patPrefix = "chirpy_pat_"
+
+var (
+	// errBadCredentials wraps every reason getPrincipal rejects a token, as
+	// opposed to failing to check it.
+	errBadCredentials = errors.New("bad credentials")
+	errInvalidPAT     = errors.New("invalid personal access token")
+	errAccountClosed  = errors.New("account suspended or deleted")
+)
+
+// principal is who a request acts for, and what it may do.
+type principal struct {
+	UserID uuid.UUID
+	// Role comes from the access token. It is empty for personal access
+	// tokens and tokens issued before roles existed, which both count as
+	// roleUser.
+	Role string
+	// Scopes is nil for access tokens, which are not restricted.
+	Scopes []string
+}
+
+func (p principal) isPAT() bool {
+	return p.Scopes != nil
+}
+
+func (p principal) can(scope string) bool {
+	if !p.isPAT() {
+		return true
+	}
+	return scope != scopeLoginOnly && slices.Contains(p.Scopes, scope)
+}
+
+func (p principal) hasRole(role string) bool {
+	return roleRanks[p.Role] >= roleRanks[role]
+}
+
+// getPrincipal resolves the request's bearer token, which is either a JWT
+// access token or a personal access token. Tokens of suspended accounts are
+// refused even though they are otherwise valid.
+func (cfg *apiConfig) getPrincipal(r *http.Request) (principal, error) {
+	return cfg.resolvePrincipal(r, true)
+}
+
+// resolvePrincipal is getPrincipal. When touch is false a personal access
+// token's last use is not recorded, which keeps read-only requests free of
+// writes.
+func (cfg *apiConfig) resolvePrincipal(r *http.Request, touch bool) (principal, error) {
+	p, err := cfg.getTokenPrincipal(r, touch)
+	if err != nil {
+		return principal{}, err
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), p.UserID)
+	if err == sql.ErrNoRows || (err == nil && isSuspended(user)) {
+		return principal{}, fmt.Errorf("%w: %w", errBadCredentials, errAccountClosed)
+	}
+	if err != nil {
+		return principal{}, err
+	}
+	return p, nil
+}
+
+func (cfg *apiConfig) getTokenPrincipal(r *http.Request, touch bool) (principal, error) {
+	token, err := getBearerToken(r)
+	if err != nil {
+		return principal{}, fmt.Errorf("%w: %w", errBadCredentials, err)
+	}
+	if !strings.HasPrefix(token, patPrefix) {
+		access, err := cfg.jwtKeys.ValidateAccessJWT(token)
+		if err != nil {
+			return principal{}, fmt.Errorf("%w: %w", errBadCredentials, err)
+		}
+		return principal{UserID: access.UserID, Role: access.Role}, nil
+	}
+	pat, err := cfg.db.GetActivePersonalAccessToken(context.Background(), auth.HashToken(token))
+	if err == sql.ErrNoRows {
+		return principal{}, fmt.Errorf("%w: %w", errBadCredentials, errInvalidPAT)
+	}
+	if err != nil {
+		return principal{}, err
+	}
+	if touch {
+		if err := cfg.db.TouchPersonalAccessToken(context.Background(), pat.ID); err != nil {
+			return principal{}, err
+		}
+	}
+	return principal{UserID: pat.UserID, Scopes: pat.Scopes}, nil
+}
+
+// authorize is how every authenticated handler finds out who is calling. It
+// accepts an access token, or a personal access token that carries scope;
+// otherwise it answers 401 or 403 and returns false.
+func (cfg *apiConfig) authorize(w http.ResponseWriter, r *http.Request, scope string) (uuid.UUID, bool) {
+	p, ok := cfg.requirePrincipal(w, r)
+	if !ok {
+		return uuid.Nil, false
+	}
+	if !p.can(scope) {
+		sendInsufficientScope(w, scope)
+		return uuid.Nil, false
+	}
+	return p.UserID, true
+}
+
+// requirePrincipal is getPrincipal for requests that must be authenticated:
+// it answers 401 or 500 itself and returns false if there is no principal.
+func (cfg *apiConfig) requirePrincipal(w http.ResponseWriter, r *http.Request) (principal, bool) {
+	if _, err := getBearerToken(r); err != nil {
+		sendUnauthorized(w, nil)
+		return principal{}, false
+	}
+	p, err := cfg.getPrincipal(r)
+	if errors.Is(err, errBadCredentials) {
+		sendUnauthorized(w, err)
+		return principal{}, false
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return principal{}, false
+	}
+	return p, true
+}
+
+type principalKey struct{}
+
+// requireRole wraps handlers that only users with role, or a more powerful
+// one, may call. The role is read from the access token, so a role change
+// applies once the user's access token is refreshed. Personal access tokens
+// carry no role and are always refused.
+func (cfg *apiConfig) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := cfg.requirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		if p.isPAT() || !p.hasRole(role) {
+			sendErrorResponse(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+// getRolePrincipal returns the principal requireRole let through.
+func getRolePrincipal(r *http.Request) principal {
+	p, _ := r.Context().Value(principalKey{}).(principal)
+	return p
+}
+
+// getViewerID returns the user behind the request's bearer token, if any.
+// Endpoints that are public but personalize their response use it, so a
+// missing or invalid token just means an anonymous viewer. Looking at public
+// listings doesn't count as using a personal access token.
+func (cfg *apiConfig) getViewerID(r *http.Request) uuid.NullUUID {
+	p, err := cfg.resolvePrincipal(r, false)
+	if err != nil || !p.can(scopeChirpsRead) {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.UserID, Valid: true}
+}
+
+func sendInsufficientScope(w http.ResponseWriter, scope string) {
+	challenge := `Bearer realm="chirpy", error="insufficient_scope"`
+	message := "Personal access tokens can't be used here"
+	if scope != scopeLoginOnly {
+		challenge += fmt.Sprintf(`, scope=%q`, scope)
+		message = "Token is missing the " + scope + " scope"
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	sendErrorResponse(w, http.StatusForbidden, message)
+}
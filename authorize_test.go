@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+func TestRequireRoleRejectsBadTokens(t *testing.T) {
+	cfg := newTestConfig(t)
+	called := false
+	handler := cfg.requireRole(roleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	for _, tc := range []struct {
+		name  string
+		token string
+	}{
+		{"no token", ""},
+		{"garbage token", "not-a-jwt"},
+	} {
+		rec := serve(t, handler, "GET", "/admin/metrics", tc.token, nil)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", tc.name, rec.Code)
+		}
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: missing WWW-Authenticate header", tc.name)
+		}
+	}
+	if called {
+		t.Error("requireRole called the handler for an unauthenticated request")
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	moderator := createTestUser(t, cfg, "moderator@example.com", roleModerator)
+	admin := createTestUser(t, cfg, "admin@example.com", roleAdmin)
+	suspended := createTestUser(t, cfg, "suspended@example.com", roleAdmin)
+	until := time.Now().Add(time.Hour).UTC()
+	if _, err := cfg.db.SetAccountState(context.Background(), database.SetAccountStateParams{ID: suspended.ID, SuspendedUntil: &until}); err != nil {
+		t.Fatalf("Error suspending user: %v", err)
+	}
+	pat := patPrefix + "test-admin-token"
+	if _, err := cfg.db.CreatePersonalAccessToken(context.Background(), database.CreatePersonalAccessTokenParams{
+		UserID:    admin.ID,
+		Name:      "test",
+		TokenHash: auth.HashToken(pat),
+		Scopes:    allScopes,
+	}); err != nil {
+		t.Fatalf("Error creating personal access token: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		role  string
+		token string
+		want  int
+	}{
+		{"user on admin endpoint", roleAdmin, makeTestToken(t, cfg, user), http.StatusForbidden},
+		{"moderator on admin endpoint", roleAdmin, makeTestToken(t, cfg, moderator), http.StatusForbidden},
+		{"moderator on moderator endpoint", roleModerator, makeTestToken(t, cfg, moderator), http.StatusOK},
+		{"admin on moderator endpoint", roleModerator, makeTestToken(t, cfg, admin), http.StatusOK},
+		{"admin on admin endpoint", roleAdmin, makeTestToken(t, cfg, admin), http.StatusOK},
+		{"admin's personal access token", roleAdmin, pat, http.StatusForbidden},
+		{"suspended admin", roleAdmin, makeTestToken(t, cfg, suspended), http.StatusUnauthorized},
+	} {
+		rec := serve(t, cfg.requireRole(tc.role, cfg.metricsHandler), "GET", "/admin/metrics", tc.token, nil)
+		if rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestAuthorizeChecksScopes(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	pat := patPrefix + "test-read-token"
+	if _, err := cfg.db.CreatePersonalAccessToken(context.Background(), database.CreatePersonalAccessTokenParams{
+		UserID:    user.ID,
+		Name:      "read only",
+		TokenHash: auth.HashToken(pat),
+		Scopes:    []string{scopeChirpsRead},
+	}); err != nil {
+		t.Fatalf("Error creating personal access token: %v", err)
+	}
+
+	rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", pat, map[string]any{"body": "hello"})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("POST /api/chirps with a read-only token = %d, want 403", rec.Code)
+	}
+	rec = serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", makeTestToken(t, cfg, user), map[string]any{"body": "hello"})
+	if rec.Code != http.StatusCreated {
+		t.Errorf("POST /api/chirps with an access token = %d %s, want 201", rec.Code, rec.Body)
+	}
+}
+
+func TestSetUserRoleKeepsAnAdmin(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	admin := createTestUser(t, cfg, "admin@example.com", roleAdmin)
+	token := makeTestToken(t, cfg, admin)
+	handler := cfg.requireRole(roleAdmin, cfg.handleSetUserRole)
+	demote := func() int {
+		t.Helper()
+		rec := serve(t, handler, "PUT", "/admin/users/"+admin.ID.String()+"/role", token, map[string]string{"role": roleUser}, "id", admin.ID.String())
+		return rec.Code
+	}
+
+	if got := demote(); got != http.StatusConflict {
+		t.Errorf("demoting the last admin = %d, want 409", got)
+	}
+	createTestUser(t, cfg, "admin2@example.com", roleAdmin)
+	if got := demote(); got != http.StatusOK {
+		t.Errorf("demoting one of two admins = %d, want 200", got)
+	}
+}
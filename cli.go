@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+const usage = `Usage: chirpy [command]
+
+Without a command, chirpy starts the server.
+
+Commands:
+  bootstrap-admin <email>  Make the user with this email the first admin`
+
+// runCommand runs a one-off maintenance command instead of the server and
+// returns the process exit code.
+func runCommand(db *sql.DB, args []string) int {
+	switch args[0] {
+	case "bootstrap-admin":
+		if len(args) != 2 {
+			fmt.Println(usage)
+			return 2
+		}
+		return bootstrapAdmin(database.New(db), args[1])
+	default:
+		fmt.Println(usage)
+		return 2
+	}
+}
+
+// bootstrapAdmin promotes an existing user to admin. It only works while
+// there are no admins; after that admins promote each other through
+// PUT /admin/users/{id}/role.
+func bootstrapAdmin(q *database.Queries, email string) int {
+	ctx := context.Background()
+	user, err := q.BootstrapAdmin(ctx, email)
+	if err == sql.ErrNoRows {
+		if _, err := q.GetUserByEmail(ctx, email); err == sql.ErrNoRows {
+			fmt.Printf("No user with email %s\n", email)
+		} else if err != nil {
+			fmt.Printf("Error looking up user: %v\n", err)
+		} else {
+			fmt.Println("An admin already exists")
+		}
+		return 1
+	}
+	if err != nil {
+		fmt.Printf("Error promoting user: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s is now an admin\n", user.Email)
+	return 0
+}
@@ -2,33 +2,45 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aleksaelezovic/chirpy/internal/auth"
 	"github.com/aleksaelezovic/chirpy/internal/database"
 	"github.com/google/uuid"
 )
 
+// maxWebhookBodyBytes caps the webhook body, which is read in full so that
+// its signature can be checked before it is parsed.
+const maxWebhookBodyBytes = 64 << 10
+
 func (cfg *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := getApiKey(r)
-	if err != nil || apiKey != cfg.polkaApiKey {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !cfg.authenticatePolka(r, payload) {
 		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	var body struct {
+		ID    string `json:"id"`
 		Event string `json:"event"`
 		Data  struct {
 			UserID uuid.UUID `json:"user_id"`
 		} `json:"data"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+	if err := json.Unmarshal(payload, &body); err != nil {
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -37,7 +49,39 @@ func (cfg *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request)
 		w.Write([]byte{})
 		return
 	}
-	_, err = cfg.db.ChangeChirpyRedStatus(context.Background(), database.ChangeChirpyRedStatusParams{
+	// Only events that are applied need an id to be deduplicated by.
+	if body.ID == "" && cfg.polkaVerifier != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Missing event id")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// The event is recorded in the same transaction that applies it, so a
+	// delivery that fails can be retried while a replay of one that
+	// succeeded is acknowledged without being applied again.
+	if body.ID != "" {
+		recorded, err := qtx.RecordWebhookEvent(context.Background(), database.RecordWebhookEventParams{
+			ID:    body.ID,
+			Event: body.Event,
+		})
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if recorded == 0 {
+			w.WriteHeader(204)
+			w.Write([]byte{})
+			return
+		}
+	}
+	_, err = qtx.ChangeChirpyRedStatus(context.Background(), database.ChangeChirpyRedStatusParams{
 		ID:          body.Data.UserID,
 		IsChirpyRed: true,
 	})
@@ -49,27 +93,48 @@ func (cfg *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	w.WriteHeader(204)
 	w.Write([]byte{})
 }
 
+// authenticatePolka checks the Polka-Signature header when webhook secrets
+// are configured. Otherwise it falls back to the static ApiKey header.
+func (cfg *apiConfig) authenticatePolka(r *http.Request, payload []byte) bool {
+	if cfg.polkaVerifier != nil {
+		return cfg.polkaVerifier.Verify(r.Header.Get("Polka-Signature"), payload) == nil
+	}
+	apiKey, err := getApiKey(r)
+	return err == nil && cfg.polkaApiKey != "" &&
+		subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polkaApiKey)) == 1
+}
+
 func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	token, err := getBearerToken(r)
-	if err != nil {
-		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok {
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
 	if err != nil {
-		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	chirp, err := cfg.db.GetChirpByID(context.Background(), id)
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetChirpByIDForUpdate(context.Background(), id)
+	if err == nil && chirp.DeletedAt != nil {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			sendErrorResponse(w, http.StatusNotFound, "Not found")
@@ -82,7 +147,11 @@ func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request)
 		sendErrorResponse(w, http.StatusForbidden, "Unauthorized")
 		return
 	}
-	if err = cfg.db.DeleteChirp(context.Background(), id); err != nil {
+	if err = removeChirp(context.Background(), qtx, chirp); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -90,13 +159,40 @@ func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte{})
 }
 
+// removeChirp deletes a chirp, or turns it into a tombstone if it has
+// replies. The reply_count trigger updates the parent and removes tombstones
+// up the thread that are left without replies.
+func removeChirp(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if chirp.ReplyCount == 0 {
+		return q.DeleteChirp(ctx, chirp.ID)
+	}
+	// Keep a tombstone so the replies still hang off the thread.
+	if err := q.TombstoneChirp(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := q.DeleteChirpRevisions(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := q.DeleteRechirpsOf(ctx, chirp.ID); err != nil {
+		return err
+	}
+	return q.DeleteChirpHashtags(ctx, chirp.ID)
+}
+
 func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	viewerID := cfg.getViewerID(r)
 	chirp, err := cfg.db.GetChirpByID(context.Background(), id)
+	if err == nil && chirp.DeletedAt != nil {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		err = cfg.checkChirpVisible(context.Background(), viewerID, chirp)
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			sendErrorResponse(w, http.StatusNotFound, "Not found")
@@ -105,47 +201,477 @@ func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request)
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	sendJSONResponse(w, http.StatusOK, chirp)
+	responses, err := cfg.toChirpResponses(context.Background(), viewerID, []database.Chirp{chirp})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, responses[0])
+}
+
+// checkChirpVisible returns sql.ErrNoRows if viewerID may not see chirp,
+// which is the case for chirps of shadow-banned users unless the viewer is
+// the author, and for chirps of users the viewer blocked or was blocked by.
+func (cfg *apiConfig) checkChirpVisible(ctx context.Context, viewerID uuid.NullUUID, chirp database.Chirp) error {
+	if viewerID.Valid && viewerID.UUID == chirp.UserID {
+		return nil
+	}
+	if err := checkNotShadowBanned(ctx, cfg.db, viewerID, chirp); err != nil || !viewerID.Valid {
+		return err
+	}
+	blocked, err := cfg.db.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{
+		A: viewerID.UUID,
+		B: chirp.UserID,
+	})
+	if err == nil && blocked {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// checkNotShadowBanned returns sql.ErrNoRows if chirp's author is
+// shadow-banned and viewerID isn't the author, so that nobody else can find
+// out the chirp exists.
+func checkNotShadowBanned(ctx context.Context, q *database.Queries, viewerID uuid.NullUUID, chirp database.Chirp) error {
+	if viewerID.Valid && viewerID.UUID == chirp.UserID {
+		return nil
+	}
+	author, err := q.GetUserByID(ctx, chirp.UserID)
+	if err == nil && author.ShadowBannedAt != nil {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+type threadReply struct {
+	database.Chirp
+	Depth int32 `json:"depth"`
+}
+
+type chirpThread struct {
+	Chirp      database.Chirp   `json:"chirp"`
+	Ancestors  []database.Chirp `json:"ancestors"`
+	Replies    []threadReply    `json:"replies"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handleGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	viewerID := cfg.getViewerID(r)
+	chirp, err := cfg.db.GetChirpByID(context.Background(), id)
+	if err == nil {
+		err = cfg.checkChirpVisible(context.Background(), viewerID, chirp)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ancestors, err := cfg.db.GetChirpAncestors(context.Background(), database.GetChirpAncestorsParams{
+		ID:       id,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if ancestors == nil {
+		ancestors = []database.Chirp{}
+	}
+	rows, err := cfg.db.GetChirpDescendants(context.Background(), database.GetChirpDescendantsParams{
+		ID:       id,
+		ViewerID: viewerID,
+		CursorID: page.cursorID(),
+		Limit:    page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, nextCursor := paginate(rows, page, func(row database.GetChirpDescendantsRow) pageCursor {
+		return chirpCursor(row.Chirp)
+	})
+	replies := make([]threadReply, len(rows))
+	for i, row := range rows {
+		replies[i] = threadReply{Chirp: row.Chirp, Depth: row.Depth}
+	}
+	sendJSONResponse(w, http.StatusOK, chirpThread{
+		Chirp:      chirp,
+		Ancestors:  ancestors,
+		Replies:    replies,
+		NextCursor: nextCursor,
+	})
+}
+
+type chirpResponse struct {
+	database.Chirp
+	LikedByMe *bool           `json:"liked_by_me,omitempty"`
+	Original  *database.Chirp `json:"original,omitempty"`
+}
+
+type chirpsPage struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) toChirpResponses(ctx context.Context, viewerID uuid.NullUUID, chirps []database.Chirp) ([]chirpResponse, error) {
+	responses := make([]chirpResponse, len(chirps))
+	var originalIDs []uuid.UUID
+	for i, chirp := range chirps {
+		responses[i].Chirp = chirp
+		if chirp.RechirpOf.Valid {
+			originalIDs = append(originalIDs, chirp.RechirpOf.UUID)
+		} else if chirp.QuoteOf.Valid {
+			originalIDs = append(originalIDs, chirp.QuoteOf.UUID)
+		}
+	}
+	if len(originalIDs) > 0 {
+		originals, err := cfg.db.GetChirpsByIDs(ctx, database.GetChirpsByIDsParams{
+			Ids:      originalIDs,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[uuid.UUID]*database.Chirp, len(originals))
+		for i := range originals {
+			byID[originals[i].ID] = &originals[i]
+		}
+		for i := range responses {
+			if responses[i].RechirpOf.Valid {
+				responses[i].Original = byID[responses[i].RechirpOf.UUID]
+			} else if responses[i].QuoteOf.Valid {
+				responses[i].Original = byID[responses[i].QuoteOf.UUID]
+			}
+		}
+	}
+	if !viewerID.Valid || len(chirps) == 0 {
+		return responses, nil
+	}
+	ids := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		ids[i] = chirp.ID
+	}
+	likedIDs, err := cfg.db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+		UserID:   viewerID.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	liked := make(map[uuid.UUID]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for i := range responses {
+		likedByMe := liked[responses[i].ID]
+		responses[i].LikedByMe = &likedByMe
+	}
+	return responses, nil
+}
+
+// getOriginalChirpForUpdate locks the chirp with the given ID so that userID
+// can reply to, quote, like or rechirp it. A plain rechirp stands in for the
+// chirp it reposts. Tombstones, and chirps of shadow-banned users other than
+// userID, count as not found.
+func getOriginalChirpForUpdate(ctx context.Context, q *database.Queries, userID, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := q.GetChirpByIDForUpdate(ctx, id)
+	if err == nil && chirp.RechirpOf.Valid {
+		chirp, err = q.GetChirpByIDForUpdate(ctx, chirp.RechirpOf.UUID)
+	}
+	if err == nil && chirp.DeletedAt != nil {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		err = checkNotShadowBanned(ctx, q, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	}
+	return chirp, err
+}
+
+func chirpCursor(c database.Chirp) pageCursor {
+	return pageCursor{CreatedAt: c.CreatedAt, ID: c.ID}
 }
 
 func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
-	authorID := r.URL.Query().Get("author_id")
-	var chirps []database.Chirp
-	var err error
-	if authorID != "" {
-		authorUUID, err := uuid.Parse(authorID)
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var sortDesc bool
+	switch strings.ToLower(r.URL.Query().Get("sort")) {
+	case "", "asc":
+	case "desc":
+		sortDesc = true
+	default:
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid sort")
+		return
+	}
+
+	viewerID := cfg.getViewerID(r)
+	var authorUUID uuid.NullUUID
+	if authorID := r.URL.Query().Get("author_id"); authorID != "" {
+		authorUUID.UUID, err = uuid.Parse(authorID)
 		if err != nil {
 			sendErrorResponse(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		chirps, err = cfg.db.GetChirpsByAuthor(context.Background(), authorUUID)
-	} else {
-		chirps, err = cfg.db.GetAllChirps(context.Background())
+		authorUUID.Valid = true
+	}
+
+	// Clients written before pagination expect every chirp in a bare array,
+	// so they get all the pages at once.
+	if !page.Paged {
+		page.Limit = maxPageLimit
+		all := []database.Chirp{}
+		for {
+			chirps, err := cfg.getChirpsPage(viewerID, authorUUID, sortDesc, page)
+			if err != nil {
+				sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			chirps, nextCursor := paginate(chirps, page, chirpCursor)
+			all = append(all, chirps...)
+			if nextCursor == "" {
+				break
+			}
+			cursor := chirpCursor(all[len(all)-1])
+			page.Cursor = &cursor
+		}
+		responses, err := cfg.toChirpResponses(context.Background(), viewerID, all)
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		sendJSONResponse(w, http.StatusOK, responses)
+		return
 	}
+
+	chirps, err := cfg.getChirpsPage(viewerID, authorUUID, sortDesc, page)
 	if err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	chirps, nextCursor := paginate(chirps, page, chirpCursor)
+	responses, err := cfg.toChirpResponses(context.Background(), viewerID, chirps)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, chirpsPage{
+		Chirps:     responses,
+		NextCursor: nextCursor,
+	})
+}
 
-	sortDesc := strings.ToLower(r.URL.Query().Get("sort")) == "desc"
+// getChirpsPage runs the listing query for GET /api/chirps, optionally
+// limited to one author.
+func (cfg *apiConfig) getChirpsPage(viewerID, authorID uuid.NullUUID, sortDesc bool, page pageParams) ([]database.Chirp, error) {
+	if authorID.Valid {
+		if sortDesc {
+			return cfg.db.GetChirpsByAuthorDesc(context.Background(), database.GetChirpsByAuthorDescParams{
+				ViewerID:        viewerID,
+				UserID:          authorID.UUID,
+				CursorCreatedAt: page.cursorCreatedAt(),
+				CursorID:        page.cursorID(),
+				Limit:           page.queryLimit(),
+			})
+		}
+		return cfg.db.GetChirpsByAuthor(context.Background(), database.GetChirpsByAuthorParams{
+			ViewerID:        viewerID,
+			UserID:          authorID.UUID,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			Limit:           page.queryLimit(),
+		})
+	}
 	if sortDesc {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
+		return cfg.db.GetAllChirpsDesc(context.Background(), database.GetAllChirpsDescParams{
+			ViewerID:        viewerID,
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			Limit:           page.queryLimit(),
 		})
 	}
+	return cfg.db.GetAllChirps(context.Background(), database.GetAllChirpsParams{
+		ViewerID:        viewerID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+}
 
-	sendJSONResponse(w, http.StatusOK, chirps)
+func (cfg *apiConfig) handleSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := buildSearchQuery(r.URL.Query().Get("q"))
+	if query == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Missing search query")
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var authorID uuid.NullUUID
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		authorID.UUID, err = uuid.Parse(s)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		authorID.Valid = true
+	}
+
+	viewerID := cfg.getViewerID(r)
+	rows, err := cfg.db.SearchChirps(context.Background(), database.SearchChirpsParams{
+		Query:      query,
+		ViewerID:   viewerID,
+		AuthorID:   authorID,
+		CursorRank: page.cursorRank(),
+		CursorID:   page.cursorID(),
+		Limit:      page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, nextCursor := paginate(rows, page, func(row database.SearchChirpsRow) pageCursor {
+		return pageCursor{CreatedAt: row.Chirp.CreatedAt, ID: row.Chirp.ID, Rank: row.Rank}
+	})
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = row.Chirp
+	}
+	responses, err := cfg.toChirpResponses(context.Background(), viewerID, chirps)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, chirpsPage{
+		Chirps:     responses,
+		NextCursor: nextCursor,
+	})
 }
 
 func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
-	tokenString, err := getBearerToken(r)
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok || !cfg.checkCanPost(w, userID) {
+		return
+	}
+
+	var body struct {
+		Body      string        `json:"body"`
+		InReplyTo uuid.NullUUID `json:"in_reply_to"`
+		QuoteOf   uuid.NullUUID `json:"quote_of"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chirpBody, flagged, err := cfg.validateChirpBody(body.Body)
 	if err != nil {
-		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	userID, err := auth.ValidateJWT(tokenString, cfg.jwtSecret)
+	if !checkMentionsAllowed(w, cfg.db, userID, chirpBody) {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
 	if err != nil {
-		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	var parent database.Chirp
+	if body.InReplyTo.Valid {
+		parent, err = getOriginalChirpForUpdate(context.Background(), qtx, userID, body.InReplyTo.UUID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				sendErrorResponse(w, http.StatusNotFound, "Chirp to reply to not found")
+				return
+			}
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !checkNotBlocked(w, qtx, userID, parent.UserID, "You can't reply to this user") {
+			return
+		}
+		body.InReplyTo.UUID = parent.ID
+	}
+	if body.QuoteOf.Valid {
+		quoted, err := getOriginalChirpForUpdate(context.Background(), qtx, userID, body.QuoteOf.UUID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				sendErrorResponse(w, http.StatusNotFound, "Chirp to quote not found")
+				return
+			}
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		body.QuoteOf.UUID = quoted.ID
+	}
+	chirp, err := qtx.CreateChirp(context.Background(), database.CreateChirpParams{
+		UserID:    userID,
+		Body:      chirpBody,
+		InReplyTo: body.InReplyTo,
+		QuoteOf:   body.QuoteOf,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = saveChirpHashtags(context.Background(), qtx, chirp); err == nil {
+		err = saveChirpFlags(context.Background(), qtx, chirp.ID, flagged)
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if body.InReplyTo.Valid {
+		err = notify(context.Background(), qtx, parent.UserID, userID, notificationReply, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	}
+	if err == nil {
+		err = notifyMentions(context.Background(), qtx, chirp, "")
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	responses, err := cfg.toChirpResponses(context.Background(), uuid.NullUUID{}, []database.Chirp{chirp})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusCreated, responses[0])
+}
+
+func (cfg *apiConfig) handleUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok || !cfg.checkCanPost(w, userID) {
 		return
 	}
 
@@ -156,20 +682,124 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if len(body.Body) > 140 {
-		sendErrorResponse(w, http.StatusBadRequest, "Chirp is too long")
+	chirpBody, flagged, err := cfg.validateChirpBody(body.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !checkMentionsAllowed(w, cfg.db, userID, chirpBody) {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	chirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
-		UserID: userID,
-		Body:   sanitizeChirpBody(body.Body),
+	chirp, err := qtx.GetChirpByIDForUpdate(context.Background(), id)
+	if err == nil && chirp.DeletedAt != nil {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if chirp.UserID != userID {
+		sendErrorResponse(w, http.StatusForbidden, "Unauthorized")
+		return
+	}
+	if chirp.RechirpOf.Valid {
+		sendErrorResponse(w, http.StatusBadRequest, "Cannot edit a rechirp")
+		return
+	}
+	if chirp.Body == chirpBody {
+		cfg.sendChirpResponse(w, http.StatusOK, userID, chirp)
+		return
+	}
+
+	previousBody := chirp.Body
+	_, err = qtx.CreateChirpRevision(context.Background(), database.CreateChirpRevisionParams{
+		ChirpID:   chirp.ID,
+		Body:      chirp.Body,
+		CreatedAt: chirp.UpdatedAt,
 	})
 	if err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	sendJSONResponse(w, http.StatusCreated, chirp)
+	chirp, err = qtx.UpdateChirp(context.Background(), database.UpdateChirpParams{
+		ID:   chirp.ID,
+		Body: chirpBody,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = saveChirpHashtags(context.Background(), qtx, chirp); err == nil {
+		err = saveChirpFlags(context.Background(), qtx, chirp.ID, flagged)
+	}
+	if err == nil {
+		err = notifyMentions(context.Background(), qtx, chirp, previousBody)
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cfg.sendChirpResponse(w, http.StatusOK, userID, chirp)
+}
+
+// sendChirpResponse sends chirp in the same shape as the listings, as seen
+// by viewerID.
+func (cfg *apiConfig) sendChirpResponse(w http.ResponseWriter, status int, viewerID uuid.UUID, chirp database.Chirp) {
+	responses, err := cfg.toChirpResponses(context.Background(), uuid.NullUUID{UUID: viewerID, Valid: true}, []database.Chirp{chirp})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, status, responses[0])
+}
+
+func (cfg *apiConfig) handleGetChirpHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chirp, err := cfg.db.GetChirpByID(context.Background(), id)
+	if err == nil && chirp.DeletedAt != nil {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		err = cfg.checkChirpVisible(context.Background(), cfg.getViewerID(r), chirp)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	revisions, err := cfg.db.GetChirpRevisions(context.Background(), id)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if revisions == nil {
+		revisions = []database.ChirpRevision{}
+	}
+	sendJSONResponse(w, http.StatusOK, revisions)
 }
 
 func (cfg *apiConfig) handleRevokeRefreshToken(w http.ResponseWriter, r *http.Request) {
@@ -193,19 +823,88 @@ func (cfg *apiConfig) handleRefreshToken(w http.ResponseWriter, r *http.Request)
 		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
-	user, err := cfg.db.GetUserFromRefreshToken(context.Background(), refreshToken)
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
 	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	row, err := qtx.GetRefreshTokenForUpdate(context.Background(), refreshToken)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if row.RefreshToken.RevokedAt.Valid {
+		// A rotated-out token came back, so it has been copied. Log out
+		// every session descended from the same login.
+		if err = qtx.RevokeRefreshTokenFamily(context.Background(), row.RefreshToken.FamilyID); err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		sendErrorResponse(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	if row.Expired {
 		sendErrorResponse(w, http.StatusUnauthorized, "Invalid token")
 		return
 	}
-	tokenString, err := auth.MakeJWT(user.ID, cfg.jwtSecret, 1*time.Hour)
+
+	if err = qtx.RevokeRefreshToken(context.Background(), refreshToken); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, err = qtx.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		Token:     newRefreshToken,
+		UserID:    row.RefreshToken.UserID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		FamilyID:  row.RefreshToken.FamilyID,
+		UserAgent: r.UserAgent(),
+		Ip:        getClientIP(r),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// The role is read again so that role changes reach the new access token.
+	user, err := qtx.GetUserByID(context.Background(), row.RefreshToken.UserID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !checkNotSuspended(w, user) {
+		return
+	}
+	tokenString, err := cfg.jwtKeys.MakeAccessJWT(auth.AccessClaims{UserID: user.ID, Role: user.Role}, accessTokenTTL)
 	if err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	sendJSONResponse(w, http.StatusOK, struct {
-		Token string `json:"token"`
-	}{Token: tokenString})
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}{
+		Token:        tokenString,
+		RefreshToken: newRefreshToken,
+	})
 }
 
 func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -217,17 +916,52 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if !cfg.checkLoginThrottle(w, r, body.Email) {
+		return
+	}
 	user, err := cfg.db.GetUserByEmail(context.Background(), body.Email)
 	if err != nil {
+		if err != sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		cfg.recordLoginFailure(r, body.Email, uuid.NullUUID{})
 		sendErrorResponse(w, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
 	ok, err := auth.VerifyPassword(body.Password, user.HashedPassword)
 	if err != nil || !ok {
+		cfg.recordLoginFailure(r, body.Email, uuid.NullUUID{UUID: user.ID, Valid: true})
 		sendErrorResponse(w, http.StatusUnauthorized, "Incorrect email or password")
 		return
 	}
-	tokenString, err := auth.MakeJWT(user.ID, cfg.jwtSecret, 1*time.Hour)
+	if !checkNotSuspended(w, user) {
+		return
+	}
+	if user.TotpEnabledAt != nil {
+		challenge, err := cfg.jwtKeys.MakeChallengeJWT(user.ID, twoFactorChallengeTTL)
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		// Failures are only cleared once the second factor is in too.
+		sendJSONResponse(w, http.StatusOK, struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			ChallengeToken    string `json:"challenge_token"`
+		}{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		})
+		return
+	}
+	cfg.recordLoginSuccess(r, body.Email)
+	cfg.sendLoginResponse(w, r, user)
+}
+
+// sendLoginResponse starts a new session for user and responds with its
+// access and refresh tokens.
+func (cfg *apiConfig) sendLoginResponse(w http.ResponseWriter, r *http.Request, user database.User) {
+	tokenString, err := cfg.jwtKeys.MakeAccessJWT(auth.AccessClaims{UserID: user.ID, Role: user.Role}, accessTokenTTL)
 	if err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
@@ -240,7 +974,10 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 	_, err = cfg.db.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
 		Token:     refreshTokenString,
 		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(60 * 24 * time.Hour),
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		FamilyID:  uuid.New(),
+		UserAgent: r.UserAgent(),
+		Ip:        getClientIP(r),
 	})
 	if err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
@@ -266,7 +1003,7 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if !strings.Contains(body.Email, "@") {
+	if !validEmail(body.Email) {
 		sendErrorResponse(w, http.StatusBadRequest, "Invalid email")
 		return
 	}
@@ -287,45 +1024,110 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	// The account exists either way; the user can ask for another email.
+	if err := cfg.sendEmailVerification(context.Background(), user.ID, user.Email); err != nil {
+		log.Printf("email verification: %v", err)
+	}
 	sendJSONResponse(w, http.StatusCreated, user)
 }
 
 func (cfg *apiConfig) handleUpdateCredentials(w http.ResponseWriter, r *http.Request) {
-	token, err := getBearerToken(r)
-	if err != nil {
-		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
-		return
-	}
 	var body struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email       *string `json:"email"`
+		Password    *string `json:"password"`
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
 	}
-	if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	hashedPassword, err := auth.HashPassword(body.Password)
-	if err != nil {
-		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+	// Personal access tokens may edit the profile but not the credentials.
+	scope := scopeProfileWrite
+	if body.Email != nil || body.Password != nil {
+		scope = scopeLoginOnly
+	}
+	userID, ok := cfg.authorize(w, r, scope)
+	if !ok {
 		return
 	}
-	user, err := cfg.db.UpdateCredentials(context.Background(), database.UpdateCredentialsParams{
-		ID:             userID,
-		Email:          body.Email,
-		HashedPassword: hashedPassword,
-	})
+	params := database.UpdateUserParams{ID: userID}
+	if body.Email != nil {
+		if !validEmail(*body.Email) {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid email")
+			return
+		}
+		// A new address only replaces the current one once it's confirmed.
+		existing, err := cfg.db.GetUserByEmail(context.Background(), *body.Email)
+		switch {
+		case err == sql.ErrNoRows:
+			params.PendingEmail = body.Email
+		case err != nil:
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		case existing.ID != userID:
+			sendErrorResponse(w, http.StatusConflict, "Email already exists")
+			return
+		}
+	}
+	if body.Password != nil {
+		if *body.Password == "" {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid password")
+			return
+		}
+		hashedPassword, err := auth.HashPassword(*body.Password)
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		params.HashedPassword = sql.NullString{String: hashedPassword, Valid: true}
+	}
+	if body.Handle != nil {
+		handle := strings.TrimPrefix(*body.Handle, "@")
+		if !validHandle(handle) {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid handle")
+			return
+		}
+		params.Handle = &handle
+	}
+	if body.DisplayName != nil {
+		if utf8.RuneCountInString(*body.DisplayName) > maxDisplayNameLength {
+			sendErrorResponse(w, http.StatusBadRequest, "Display name is too long")
+			return
+		}
+		params.DisplayName = sql.NullString{String: *body.DisplayName, Valid: true}
+	}
+	if body.Bio != nil {
+		if utf8.RuneCountInString(*body.Bio) > maxBioLength {
+			sendErrorResponse(w, http.StatusBadRequest, "Bio is too long")
+			return
+		}
+		params.Bio = sql.NullString{String: *body.Bio, Valid: true}
+	}
+	user, err := cfg.db.UpdateUser(context.Background(), params)
 	if err != nil {
+		if strings.Contains(err.Error(), "users_handle_idx") {
+			sendErrorResponse(w, http.StatusConflict, "Handle already taken")
+			return
+		}
 		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if params.PendingEmail != nil {
+		if err := cfg.sendEmailVerification(context.Background(), userID, *params.PendingEmail); err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
 	sendJSONResponse(w, http.StatusOK, user)
 }
 
+func (cfg *apiConfig) handleGetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	sendJSONResponse(w, http.StatusOK, cfg.jwtKeys.JWKS())
+}
+
 func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(200)
@@ -337,12 +1139,8 @@ func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
 </html>`, cfg.fileserverHits.Load()))
 }
 
+// resetHandler deletes every user. Only admins can reach it.
 func (cfg *apiConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
-	if !cfg.isDev {
-		w.WriteHeader(403)
-		w.Write([]byte("Forbidden"))
-		return
-	}
 	cfg.fileserverHits.Store(0)
 	err := cfg.db.DeleteAllUsers(context.Background())
 	if err != nil {
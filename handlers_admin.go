@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validRole(body.Role) {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.GetUserByID(context.Background(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	previousRole := user.Role
+	if previousRole == roleAdmin && body.Role != roleAdmin {
+		admins, err := qtx.LockAdmins(context.Background())
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(admins) <= 1 {
+			sendErrorResponse(w, http.StatusConflict, "Cannot demote the last admin")
+			return
+		}
+	}
+	user, err = qtx.SetUserRole(context.Background(), database.SetUserRoleParams{
+		ID:   id,
+		Role: body.Role,
+	})
+	if err == nil && previousRole != body.Role {
+		err = recordAuditEvent(context.Background(), qtx, auditRoleChange, uuid.NullUUID{UUID: id, Valid: true}, getClientIP(r), map[string]any{
+			"role":          body.Role,
+			"previous_role": previousRole,
+			"changed_by":    getRolePrincipal(r).UserID,
+		})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, toAdminUserResponse(user))
+}
+
+// adminUserResponse is a user as admins see it, including the account state
+// that is hidden from the user themselves.
+type adminUserResponse struct {
+	database.User
+	State          string     `json:"state"`
+	ShadowBannedAt *time.Time `json:"shadow_banned_at"`
+}
+
+func toAdminUserResponse(user database.User) adminUserResponse {
+	return adminUserResponse{
+		User:           user,
+		State:          accountState(user),
+		ShadowBannedAt: user.ShadowBannedAt,
+	}
+}
+
+func (cfg *apiConfig) handleSetAccountState(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var body struct {
+		State          string     `json:"state"`
+		SuspendedUntil *time.Time `json:"suspended_until"`
+		Reason         string     `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.SetAccountStateParams{ID: id}
+	switch body.State {
+	case accountActive:
+	case accountSuspended:
+		if body.SuspendedUntil == nil || !body.SuspendedUntil.After(time.Now()) {
+			sendErrorResponse(w, http.StatusBadRequest, "suspended_until must be in the future")
+			return
+		}
+		// Stored in UTC because the column has no time zone.
+		until := body.SuspendedUntil.UTC()
+		params.SuspendedUntil = &until
+	case accountShadowBanned:
+		now := time.Now().UTC()
+		params.ShadowBannedAt = &now
+	default:
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid state")
+		return
+	}
+	admin := getRolePrincipal(r)
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.GetUserByID(context.Background(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if roleRanks[user.Role] >= roleRanks[admin.Role] {
+		sendErrorResponse(w, http.StatusForbidden, "Cannot change the state of a user whose role is not below yours")
+		return
+	}
+	previousState := accountState(user)
+	// Only "active" lifts restrictions; suspending a shadow-banned user or
+	// shadow-banning a suspended one keeps what is already in place.
+	switch body.State {
+	case accountSuspended:
+		params.ShadowBannedAt = user.ShadowBannedAt
+	case accountShadowBanned:
+		params.SuspendedUntil = user.SuspendedUntil
+		if user.ShadowBannedAt != nil {
+			// Keep when the ban started.
+			params.ShadowBannedAt = user.ShadowBannedAt
+		}
+	}
+	user, err = qtx.SetAccountState(context.Background(), params)
+	if err == nil && body.State == accountSuspended {
+		err = qtx.RevokeAllRefreshTokens(context.Background(), id)
+	}
+	if err == nil {
+		err = recordAuditEvent(context.Background(), qtx, auditAccountStateChange, uuid.NullUUID{UUID: id, Valid: true}, getClientIP(r), map[string]any{
+			"state":           body.State,
+			"previous_state":  previousState,
+			"suspended_until": params.SuspendedUntil,
+			"reason":          body.Reason,
+			"changed_by":      admin.UserID,
+		})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, toAdminUserResponse(user))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// Blocking works both ways: neither user sees the other's chirps, and
+// neither can follow or reply to the other. A blocked user also can't
+// mention the blocker. Muting is one-sided and only hides the muted user's
+// chirps from the muter.
+
+type blocksPage struct {
+	Blocks     []database.Block `json:"blocks"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type mutesPage struct {
+	Mutes      []database.Mute `json:"mutes"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// getRelationTarget parses the user ID in the path of a block or mute
+// request and authorizes the caller. It answers the request itself and
+// returns false if either fails.
+func (cfg *apiConfig) getRelationTarget(w http.ResponseWriter, r *http.Request, action string) (userID, targetID uuid.UUID, ok bool) {
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, ok = cfg.authorize(w, r, scopeFollowsWrite)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	if targetID == userID {
+		sendErrorResponse(w, http.StatusBadRequest, "Cannot "+action+" yourself")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+func (cfg *apiConfig) handleBlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := cfg.getRelationTarget(w, r, "block")
+	if !ok {
+		return
+	}
+	if _, err := cfg.db.GetUserByID(context.Background(), id); err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	_, err = qtx.LockUserPair(context.Background(), database.LockUserPairParams{
+		A: userID,
+		B: id,
+	})
+	if err == nil {
+		_, err = qtx.BlockUser(context.Background(), database.BlockUserParams{
+			BlockerID: userID,
+			BlockedID: id,
+		})
+	}
+	if err == nil {
+		err = qtx.DeleteFollowsBetween(context.Background(), database.DeleteFollowsBetweenParams{
+			A: userID,
+			B: id,
+		})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleUnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := cfg.getRelationTarget(w, r, "unblock")
+	if !ok {
+		return
+	}
+	err := cfg.db.UnblockUser(context.Background(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: id,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleMuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := cfg.getRelationTarget(w, r, "mute")
+	if !ok {
+		return
+	}
+	if _, err := cfg.db.GetUserByID(context.Background(), id); err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err := cfg.db.MuteUser(context.Background(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: id,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := cfg.getRelationTarget(w, r, "unmute")
+	if !ok {
+		return
+	}
+	err := cfg.db.UnmuteUser(context.Background(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: id,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeFollowsWrite)
+	if !ok {
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	blocks, err := cfg.db.GetBlocks(context.Background(), database.GetBlocksParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	blocks, nextCursor := paginate(blocks, page, func(b database.Block) pageCursor {
+		return pageCursor{CreatedAt: b.CreatedAt, ID: b.BlockedID}
+	})
+	sendJSONResponse(w, http.StatusOK, blocksPage{
+		Blocks:     blocks,
+		NextCursor: nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handleGetMutes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeFollowsWrite)
+	if !ok {
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mutes, err := cfg.db.GetMutes(context.Background(), database.GetMutesParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	mutes, nextCursor := paginate(mutes, page, func(m database.Mute) pageCursor {
+		return pageCursor{CreatedAt: m.CreatedAt, ID: m.MutedID}
+	})
+	sendJSONResponse(w, http.StatusOK, mutesPage{
+		Mutes:      mutes,
+		NextCursor: nextCursor,
+	})
+}
+
+// checkNotBlocked answers 403 and returns false if either user blocked the
+// other.
+func checkNotBlocked(w http.ResponseWriter, q *database.Queries, userID, otherID uuid.UUID, message string) bool {
+	blocked, err := q.IsBlockedEitherWay(context.Background(), database.IsBlockedEitherWayParams{
+		A: userID,
+		B: otherID,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if blocked {
+		sendErrorResponse(w, http.StatusForbidden, message)
+		return false
+	}
+	return true
+}
+
+// checkMentionsAllowed answers 403 and returns false if body mentions
+// someone who blocked its author.
+func checkMentionsAllowed(w http.ResponseWriter, q *database.Queries, authorID uuid.UUID, body string) bool {
+	handles := extractMentions(body)
+	if len(handles) == 0 {
+		return true
+	}
+	blocking, err := q.GetBlockingHandles(context.Background(), database.GetBlockingHandlesParams{
+		UserID:  authorID,
+		Handles: handles,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if len(blocking) > 0 {
+		sendErrorResponse(w, http.StatusForbidden, "You can't mention @"+strings.Join(blocking, ", @"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/aleksaelezovic/chirpy/internal/mail"
+	"github.com/google/uuid"
+)
+
+// sendEmailVerification replaces any outstanding verification tokens for
+// user with one for email and mails a confirmation link to that address.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := qtx.DeleteEmailVerificationTokens(ctx, userID); err != nil {
+		return err
+	}
+	err = qtx.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	link := cfg.publicURL + "/verify-email?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your email address for Chirpy",
+		Body: fmt.Sprintf("Please confirm that this is your email address by opening this link within %v:\n\n%s\n\n"+
+			"If you didn't sign up for Chirpy or change your email, you can ignore this email.\n", emailVerificationTTL, link),
+	})
+}
+
+// checkCanPost answers 403 and returns false if unverified accounts are not
+// allowed to post and the user hasn't verified their email address yet.
+func (cfg *apiConfig) checkCanPost(w http.ResponseWriter, userID uuid.UUID) bool {
+	if !cfg.requireVerifiedEmail {
+		return true
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if user.EmailVerifiedAt == nil {
+		sendErrorResponse(w, http.StatusForbidden, "Email address not verified")
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	verification, err := qtx.UseEmailVerificationToken(context.Background(), auth.HashToken(body.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid or expired token")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	user, err := qtx.ConfirmEmail(context.Background(), database.ConfirmEmailParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if err != nil {
+		// Someone else may have claimed the address since the change was
+		// requested.
+		if strings.Contains(err.Error(), "duplicate key") {
+			sendErrorResponse(w, http.StatusConflict, "Email already exists")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, user)
+}
+
+func (cfg *apiConfig) handleResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	email := user.Email
+	if user.PendingEmail != nil {
+		email = *user.PendingEmail
+	} else if user.EmailVerifiedAt != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Email address already verified")
+		return
+	}
+	if err := cfg.sendEmailVerification(context.Background(), userID, email); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte{})
+}
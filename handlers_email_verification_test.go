@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+func TestEmailVerification(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	mailer := &recordingMailer{}
+	cfg.mailer = mailer
+	cfg.requireVerifiedEmail = true
+
+	rec := serve(t, cfg.handleCreateUser, "POST", "/api/users", "", map[string]string{"email": "user@example.com", "password": "hunter22"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signing up = %d %s", rec.Code, rec.Body)
+	}
+	user := decodeResponse[database.User](t, rec)
+	token := makeTestToken(t, cfg, user)
+	post := func() int {
+		t.Helper()
+		return serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", token, map[string]any{"body": "hello"}).Code
+	}
+	verify := func(verificationToken string) *httptest.ResponseRecorder {
+		t.Helper()
+		return serve(t, cfg.handleVerifyEmail, "POST", "/api/email/verify", "", map[string]string{"token": verificationToken})
+	}
+	if got := post(); got != http.StatusForbidden {
+		t.Errorf("posting before verifying = %d, want 403", got)
+	}
+
+	// Resending replaces the first link.
+	if rec := serve(t, cfg.handleResendEmailVerification, "POST", "/api/email/resend", token, nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("resending = %d %s", rec.Code, rec.Body)
+	}
+	sent := mailer.sent(cfg)
+	if len(sent) != 2 || sent[0].To != user.Email || sent[1].To != user.Email {
+		t.Fatalf("sent %+v, want two messages to %s", sent, user.Email)
+	}
+	if rec := verify(tokenFromLink(t, sent[0].Body)); rec.Code != http.StatusBadRequest {
+		t.Errorf("verifying with the replaced link = %d, want 400", rec.Code)
+	}
+	rec = verify(tokenFromLink(t, sent[1].Body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("verifying = %d %s", rec.Code, rec.Body)
+	}
+	if verified := decodeResponse[database.User](t, rec); verified.EmailVerifiedAt == nil {
+		t.Errorf("verified user = %+v, want email_verified_at set", verified)
+	}
+	if got := post(); got != http.StatusCreated {
+		t.Errorf("posting after verifying = %d, want 201", got)
+	}
+	if rec := verify(tokenFromLink(t, sent[1].Body)); rec.Code != http.StatusBadRequest {
+		t.Errorf("reusing the link = %d, want 400", rec.Code)
+	}
+
+	// A new address only takes over once it is confirmed.
+	rec = serve(t, cfg.handleUpdateCredentials, "PUT", "/api/users", token, map[string]string{"email": "new@example.com"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("changing email = %d %s", rec.Code, rec.Body)
+	}
+	if changed := decodeResponse[database.User](t, rec); changed.Email != user.Email {
+		t.Errorf("email changed to %s before it was confirmed", changed.Email)
+	}
+	sent = mailer.sent(cfg)
+	if len(sent) != 3 || sent[2].To != "new@example.com" {
+		t.Fatalf("sent %+v, want a third message to new@example.com", sent)
+	}
+	rec = verify(tokenFromLink(t, sent[2].Body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("confirming the new email = %d %s", rec.Code, rec.Body)
+	}
+	if changed := decodeResponse[database.User](t, rec); changed.Email != "new@example.com" || changed.PendingEmail != nil {
+		t.Errorf("after confirming: email %s, pending %v, want new@example.com and none pending", changed.Email, changed.PendingEmail)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type followsPage struct {
+	Follows    []database.Follow `json:"follows"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handleFollowUser(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeFollowsWrite)
+	if !ok {
+		return
+	}
+	if id == userID {
+		sendErrorResponse(w, http.StatusBadRequest, "Cannot follow yourself")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Blocking locks the same users, so a block can't slip in between the
+	// check and the follow.
+	locked, err := qtx.LockUserPair(context.Background(), database.LockUserPairParams{
+		A: userID,
+		B: id,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(locked) < 2 {
+		sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if !checkNotBlocked(w, qtx, userID, id, "You can't follow this user") {
+		return
+	}
+	followed, err := qtx.FollowUser(context.Background(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: id,
+	})
+	if err == nil && followed > 0 {
+		err = notify(context.Background(), qtx, id, userID, notificationFollow, uuid.NullUUID{})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeFollowsWrite)
+	if !ok {
+		return
+	}
+	err = cfg.db.UnfollowUser(context.Background(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: id,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleGetFollowers(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	follows, err := cfg.db.GetFollowers(context.Background(), database.GetFollowersParams{
+		UserID:          id,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	follows, nextCursor := paginate(follows, page, func(f database.Follow) pageCursor {
+		return pageCursor{CreatedAt: f.CreatedAt, ID: f.FollowerID}
+	})
+	sendJSONResponse(w, http.StatusOK, followsPage{
+		Follows:    follows,
+		NextCursor: nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handleGetFollowing(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	follows, err := cfg.db.GetFollowing(context.Background(), database.GetFollowingParams{
+		UserID:          id,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	follows, nextCursor := paginate(follows, page, func(f database.Follow) pageCursor {
+		return pageCursor{CreatedAt: f.CreatedAt, ID: f.FolloweeID}
+	})
+	sendJSONResponse(w, http.StatusOK, followsPage{
+		Follows:    follows,
+		NextCursor: nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeChirpsRead)
+	if !ok {
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	chirps, err := cfg.db.GetTimeline(context.Background(), database.GetTimelineParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	chirps, nextCursor := paginate(chirps, page, chirpCursor)
+	responses, err := cfg.toChirpResponses(context.Background(), uuid.NullUUID{UUID: userID, Valid: true}, chirps)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, chirpsPage{
+		Chirps:     responses,
+		NextCursor: nextCursor,
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestRefollowDoesNotRepeatNotification(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	follower := createTestUser(t, cfg, "follower@example.com", roleUser)
+	followee := createTestUser(t, cfg, "followee@example.com", roleUser)
+	token := makeTestToken(t, cfg, follower)
+	target := "/api/users/" + followee.ID.String() + "/follow"
+
+	for range 3 {
+		if rec := serve(t, cfg.handleFollowUser, "POST", target, token, nil, "id", followee.ID.String()); rec.Code != http.StatusNoContent {
+			t.Fatalf("POST %s = %d %s, want 204", target, rec.Code, rec.Body)
+		}
+		if rec := serve(t, cfg.handleUnfollowUser, "DELETE", target, token, nil, "id", followee.ID.String()); rec.Code != http.StatusNoContent {
+			t.Fatalf("DELETE %s = %d %s, want 204", target, rec.Code, rec.Body)
+		}
+	}
+	notifications, err := cfg.db.GetNotifications(context.Background(), database.GetNotificationsParams{
+		UserID: followee.ID,
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("Error listing notifications: %v", err)
+	}
+	if len(notifications) != 1 {
+		t.Errorf("following three times left %d notifications, want 1", len(notifications))
+	}
+}
+
+func TestTimelineShowsFollowedUsers(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	carol := createTestUser(t, cfg, "carol@example.com", roleUser)
+	token := makeTestToken(t, cfg, alice)
+	if rec := serve(t, cfg.handleFollowUser, "POST", "/api/users/"+bob.ID.String()+"/follow", token, nil, "id", bob.ID.String()); rec.Code != http.StatusNoContent {
+		t.Fatalf("following bob = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, cfg.handleFollowUser, "POST", "/api/users/"+alice.ID.String()+"/follow", token, nil, "id", alice.ID.String()); rec.Code != http.StatusBadRequest {
+		t.Errorf("following yourself = %d, want 400", rec.Code)
+	}
+	older := createTestChirp(t, cfg, bob, "older", uuid.NullUUID{})
+	createTestChirp(t, cfg, carol, "not followed", uuid.NullUUID{})
+	createTestChirp(t, cfg, alice, "own chirp", uuid.NullUUID{})
+	newer := createTestChirp(t, cfg, bob, "newer", uuid.NullUUID{})
+
+	rec := serve(t, cfg.handleGetTimeline, "GET", "/api/timeline", token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/timeline = %d %s", rec.Code, rec.Body)
+	}
+	got := chirpIDs(decodeResponse[chirpsPage](t, rec).Chirps)
+	if want := []uuid.UUID{newer.ID, older.ID}; !slices.Equal(got, want) {
+		t.Errorf("timeline = %v, want %v", got, want)
+	}
+
+	for _, tc := range []struct {
+		user     database.User
+		relation string
+		want     database.Follow
+	}{
+		{alice, "following", database.Follow{FollowerID: alice.ID, FolloweeID: bob.ID}},
+		{bob, "followers", database.Follow{FollowerID: alice.ID, FolloweeID: bob.ID}},
+	} {
+		target := "/api/users/" + tc.user.ID.String() + "/" + tc.relation
+		rec := serve(t, cfg.handleGetUserRelation, "GET", target, "", nil, "id", tc.user.ID.String(), "relation", tc.relation)
+		follows := decodeResponse[followsPage](t, rec).Follows
+		if len(follows) != 1 || follows[0].FollowerID != tc.want.FollowerID || follows[0].FolloweeID != tc.want.FolloweeID {
+			t.Errorf("GET %s = %+v, want alice following bob", target, follows)
+		}
+	}
+}
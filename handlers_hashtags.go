@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+// saveChirpHashtags links a chirp to the hashtags in its body, replacing
+// whatever it was linked to before.
+func saveChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	for _, tag := range extractHashtags(chirp.Body) {
+		hashtag, err := q.UpsertHashtag(ctx, tag)
+		if err != nil {
+			return err
+		}
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			HashtagID: hashtag.ID,
+			CreatedAt: chirp.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handleGetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := normalizeHashtag(r.PathValue("tag"))
+	if tag == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid hashtag")
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	viewerID := cfg.getViewerID(r)
+	chirps, err := cfg.db.GetChirpsByHashtag(context.Background(), database.GetChirpsByHashtagParams{
+		Tag:             tag,
+		ViewerID:        viewerID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	chirps, nextCursor := paginate(chirps, page, chirpCursor)
+	responses, err := cfg.toChirpResponses(context.Background(), viewerID, chirps)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, chirpsPage{
+		Chirps:     responses,
+		NextCursor: nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handleGetTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < time.Minute || d > maxTrendingWindow {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid window")
+			return
+		}
+		window = d
+	}
+	limit := defaultTrendingLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			sendErrorResponse(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+	trending, err := cfg.db.GetTrendingHashtags(context.Background(), database.GetTrendingHashtagsParams{
+		WindowSeconds: int32(window.Seconds()),
+		Limit:         int32(limit),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if trending == nil {
+		trending = []database.GetTrendingHashtagsRow{}
+	}
+	sendJSONResponse(w, http.StatusOK, trending)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHashtagFeeds(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	token := makeTestToken(t, cfg, alice)
+	post := func(body string) chirpResponse {
+		t.Helper()
+		rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", token, map[string]any{"body": body})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("posting %q = %d %s", body, rec.Code, rec.Body)
+		}
+		return decodeResponse[chirpResponse](t, rec)
+	}
+	first := post("Learning #Go today")
+	second := post("#go #Postgres")
+	edited := post("#postgres only")
+	rec := serve(t, cfg.handleUpdateChirp, "PUT", "/api/chirps/"+edited.ID.String(), token, map[string]string{"body": "now about #go"}, "id", edited.ID.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("editing = %d %s", rec.Code, rec.Body)
+	}
+
+	feed := func(tag string) []uuid.UUID {
+		t.Helper()
+		target := "/api/hashtags/" + url.PathEscape(tag) + "/chirps"
+		rec := serve(t, cfg.handleGetHashtagChirps, "GET", target, "", nil, "tag", tag)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", target, rec.Code, rec.Body)
+		}
+		return chirpIDs(decodeResponse[chirpsPage](t, rec).Chirps)
+	}
+	if got, want := feed("GO"), []uuid.UUID{edited.ID, second.ID, first.ID}; !slices.Equal(got, want) {
+		t.Errorf("#go feed = %v, want %v", got, want)
+	}
+	// The edit took the chirp out of #postgres.
+	if got, want := feed("#postgres"), []uuid.UUID{second.ID}; !slices.Equal(got, want) {
+		t.Errorf("#postgres feed = %v, want %v", got, want)
+	}
+
+	rec = serve(t, cfg.handleGetTrendingHashtags, "GET", "/api/hashtags/trending?window=1h", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/hashtags/trending = %d %s", rec.Code, rec.Body)
+	}
+	want := []database.GetTrendingHashtagsRow{{Tag: "go", ChirpCount: 3}, {Tag: "postgres", ChirpCount: 1}}
+	if got := decodeResponse[[]database.GetTrendingHashtagsRow](t, rec); !slices.Equal(got, want) {
+		t.Errorf("trending = %+v, want %+v", got, want)
+	}
+	for _, query := range []string{"window=1s", "window=200h", "limit=0"} {
+		rec := serve(t, cfg.handleGetTrendingHashtags, "GET", "/api/hashtags/trending?"+query, "", nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/hashtags/trending?%s = %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleLikeChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := getOriginalChirpForUpdate(context.Background(), qtx, userID, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	liked, err := qtx.LikeChirp(context.Background(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err == nil && liked > 0 {
+		err = qtx.IncrementLikeCount(context.Background(), chirp.ID)
+	}
+	if err == nil && liked > 0 {
+		err = notify(context.Background(), qtx, chirp.UserID, userID, notificationLike, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Like handleLikeChirp, a rechirp stands in for its original. Tombstones
+	// are not skipped here, so a like on a deleted chirp can still be taken
+	// back, and unliking a missing chirp is a no-op.
+	chirp, err := qtx.GetChirpByIDForUpdate(context.Background(), id)
+	if err == nil && chirp.RechirpOf.Valid {
+		id = chirp.RechirpOf.UUID
+	}
+	var unliked int64
+	if err == nil || err == sql.ErrNoRows {
+		unliked, err = qtx.UnlikeChirp(context.Background(), database.UnlikeChirpParams{
+			UserID:  userID,
+			ChirpID: id,
+		})
+	}
+	if err == nil && unliked > 0 {
+		err = qtx.DecrementLikeCount(context.Background(), id)
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleGetUserLikes(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	viewerID := cfg.getViewerID(r)
+	rows, err := cfg.db.GetChirpsLikedByUser(context.Background(), database.GetChirpsLikedByUserParams{
+		UserID:          id,
+		ViewerID:        viewerID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, nextCursor := paginate(rows, page, func(row database.GetChirpsLikedByUserRow) pageCursor {
+		return pageCursor{CreatedAt: row.LikedAt, ID: row.Chirp.ID}
+	})
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = row.Chirp
+	}
+	responses, err := cfg.toChirpResponses(context.Background(), viewerID, chirps)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, chirpsPage{
+		Chirps:     responses,
+		NextCursor: nextCursor,
+	})
+}
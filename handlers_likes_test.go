@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestLikeChirp(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	chirp := createTestChirp(t, cfg, alice, "like me", uuid.NullUUID{})
+	target := "/api/chirps/" + chirp.ID.String() + "/like"
+	bobToken := makeTestToken(t, cfg, bob)
+	getChirp := func(viewer database.User) chirpResponse {
+		t.Helper()
+		rec := serve(t, cfg.handleGetChirpByID, "GET", "/api/chirps/"+chirp.ID.String(), makeTestToken(t, cfg, viewer), nil, "id", chirp.ID.String())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/chirps/%s = %d %s", chirp.ID, rec.Code, rec.Body)
+		}
+		return decodeResponse[chirpResponse](t, rec)
+	}
+
+	// Liking twice counts once.
+	for range 2 {
+		if rec := serve(t, cfg.handleLikeChirp, "POST", target, bobToken, nil, "id", chirp.ID.String()); rec.Code != http.StatusNoContent {
+			t.Fatalf("POST %s = %d %s", target, rec.Code, rec.Body)
+		}
+	}
+	if got := getChirp(bob); got.LikeCount != 1 || got.LikedByMe == nil || !*got.LikedByMe {
+		t.Errorf("as seen by bob: like_count = %d, liked_by_me = %v, want 1 and true", got.LikeCount, got.LikedByMe)
+	}
+	if got := getChirp(alice); got.LikedByMe == nil || *got.LikedByMe {
+		t.Errorf("as seen by alice: liked_by_me = %v, want false", got.LikedByMe)
+	}
+
+	likesTarget := "/api/users/" + bob.ID.String() + "/likes"
+	rec := serve(t, cfg.handleGetUserRelation, "GET", likesTarget, "", nil, "id", bob.ID.String(), "relation", "likes")
+	if got := chirpIDs(decodeResponse[chirpsPage](t, rec).Chirps); len(got) != 1 || got[0] != chirp.ID {
+		t.Errorf("GET %s = %v, want [%s]", likesTarget, got, chirp.ID)
+	}
+
+	for range 2 {
+		if rec := serve(t, cfg.handleUnlikeChirp, "DELETE", target, bobToken, nil, "id", chirp.ID.String()); rec.Code != http.StatusNoContent {
+			t.Fatalf("DELETE %s = %d %s", target, rec.Code, rec.Body)
+		}
+	}
+	if got := getChirp(bob); got.LikeCount != 0 || got.LikedByMe == nil || *got.LikedByMe {
+		t.Errorf("after unliking: like_count = %d, liked_by_me = %v, want 0 and false", got.LikeCount, got.LikedByMe)
+	}
+
+	missing := uuid.New().String()
+	if rec := serve(t, cfg.handleLikeChirp, "POST", "/api/chirps/"+missing+"/like", bobToken, nil, "id", missing); rec.Code != http.StatusNotFound {
+		t.Errorf("liking a missing chirp = %d, want 404", rec.Code)
+	}
+}
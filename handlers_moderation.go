@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// saveChirpFlags queues a chirp for review if it contains flagged terms, and
+// takes it out of the queue once an edit removes them.
+func saveChirpFlags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, terms []string) error {
+	if len(terms) == 0 {
+		return q.UnflagChirp(ctx, chirpID)
+	}
+	return q.FlagChirp(ctx, database.FlagChirpParams{
+		ChirpID: chirpID,
+		Terms:   terms,
+	})
+}
+
+func (cfg *apiConfig) handleGetFlaggedChirps(w http.ResponseWriter, r *http.Request) {
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rows, err := cfg.db.GetFlaggedChirps(context.Background(), database.GetFlaggedChirpsParams{
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, nextCursor := paginate(rows, page, func(row database.GetFlaggedChirpsRow) pageCursor {
+		return pageCursor{CreatedAt: row.FlaggedAt, ID: row.Chirp.ID}
+	})
+	sendJSONResponse(w, http.StatusOK, struct {
+		Flags      []database.GetFlaggedChirpsRow `json:"flags"`
+		NextCursor string                         `json:"next_cursor,omitempty"`
+	}{
+		Flags:      rows,
+		NextCursor: nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handleReloadModerationTerms(w http.ResponseWriter, r *http.Request) {
+	if err := cfg.moderator.Reload(context.Background()); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, struct {
+		Terms int `json:"terms"`
+	}{
+		Terms: cfg.moderator.Len(),
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/aleksaelezovic/chirpy/internal/moderation"
+)
+
+func TestModeratedChirps(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	path := filepath.Join(t.TempDir(), "terms.txt")
+	writeTerms := func(terms string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(terms), 0o600); err != nil {
+			t.Fatalf("Error writing terms: %v", err)
+		}
+	}
+	writeTerms("fornax\nsharbert flag\nkerfuffle reject\n")
+	moderator, err := moderation.NewModerator(context.Background(), moderation.FileSource{Path: path})
+	if err != nil {
+		t.Fatalf("Error creating moderator: %v", err)
+	}
+	cfg.moderator = moderator
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	admin := createTestUser(t, cfg, "admin@example.com", roleAdmin)
+	token := makeTestToken(t, cfg, user)
+	adminToken := makeTestToken(t, cfg, admin)
+	post := func(body string) (chirpResponse, int) {
+		t.Helper()
+		rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", token, map[string]any{"body": body})
+		if rec.Code != http.StatusCreated {
+			return chirpResponse{}, rec.Code
+		}
+		return decodeResponse[chirpResponse](t, rec), rec.Code
+	}
+	flagged := func() []database.GetFlaggedChirpsRow {
+		t.Helper()
+		rec := serve(t, cfg.requireRole(roleModerator, cfg.handleGetFlaggedChirps), "GET", "/admin/moderation/flags", adminToken, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /admin/moderation/flags = %d %s", rec.Code, rec.Body)
+		}
+		return decodeResponse[struct {
+			Flags []database.GetFlaggedChirpsRow `json:"flags"`
+		}](t, rec).Flags
+	}
+
+	if chirp, code := post("what a F0RNAX!"); code != http.StatusCreated || chirp.Body != "what a ****!" {
+		t.Errorf("posting a masked term = %d %q, want 201 %q", code, chirp.Body, "what a ****!")
+	}
+	if _, code := post("such a kerfuffle"); code != http.StatusBadRequest {
+		t.Errorf("posting a rejected term = %d, want 400", code)
+	}
+	chirp, code := post("hello sharbert")
+	if code != http.StatusCreated || chirp.Body != "hello sharbert" {
+		t.Fatalf("posting a flagged term = %d %q, want 201 and the body as written", code, chirp.Body)
+	}
+	if flags := flagged(); len(flags) != 1 || flags[0].Chirp.ID != chirp.ID || len(flags[0].Terms) != 1 || flags[0].Terms[0] != "sharbert" {
+		t.Errorf("flagged chirps = %+v, want the sharbert chirp", flags)
+	}
+	// Editing the term out takes the chirp out of the queue.
+	rec := serve(t, cfg.handleUpdateChirp, "PUT", "/api/chirps/"+chirp.ID.String(), token, map[string]string{"body": "hello there"}, "id", chirp.ID.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("editing = %d %s", rec.Code, rec.Body)
+	}
+	if flags := flagged(); len(flags) != 0 {
+		t.Errorf("flagged chirps after the edit = %+v, want none", flags)
+	}
+
+	writeTerms("fornax\nkerfuffle\nsharbert\nzorp reject\n")
+	rec = serve(t, cfg.requireRole(roleAdmin, cfg.handleReloadModerationTerms), "POST", "/admin/moderation/reload", adminToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reloading terms = %d %s", rec.Code, rec.Body)
+	}
+	if got := decodeResponse[struct {
+		Terms int `json:"terms"`
+	}](t, rec).Terms; got != 4 {
+		t.Errorf("reloaded %d terms, want 4", got)
+	}
+	if chirp, code := post("such a kerfuffle"); code != http.StatusCreated || chirp.Body != "such a ****" {
+		t.Errorf("posting a term that is now masked = %d %q, want 201 %q", code, chirp.Body, "such a ****")
+	}
+	if _, code := post("zorp"); code != http.StatusBadRequest {
+		t.Errorf("posting a newly rejected term = %d, want 400", code)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationMention = "mention"
+	notificationLike    = "like"
+	notificationFollow  = "follow"
+	notificationReply   = "reply"
+)
+
+type notificationsPage struct {
+	Notifications []database.Notification `json:"notifications"`
+	UnreadCount   int64                   `json:"unread_count"`
+	NextCursor    string                  `json:"next_cursor,omitempty"`
+}
+
+// notify records a notification for userID about something actorID did.
+// Users are never notified about their own actions.
+func notify(ctx context.Context, q *database.Queries, userID, actorID uuid.UUID, notificationType string, chirpID uuid.NullUUID) error {
+	if userID == actorID {
+		return nil
+	}
+	return q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		ActorID: actorID,
+		Type:    notificationType,
+		ChirpID: chirpID,
+	})
+}
+
+// notifyMentions notifies every user whose @handle appears in the chirp but
+// not in previousBody, so that an edit only notifies newly mentioned users.
+func notifyMentions(ctx context.Context, q *database.Queries, chirp database.Chirp, previousBody string) error {
+	handles := extractMentions(chirp.Body)
+	if previous := extractMentions(previousBody); len(previous) > 0 {
+		handles = slices.DeleteFunc(handles, func(handle string) bool {
+			return slices.Contains(previous, handle)
+		})
+	}
+	if len(handles) == 0 {
+		return nil
+	}
+	users, err := q.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return err
+	}
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+	for _, user := range users {
+		if err := notify(ctx, q, user.ID, chirp.UserID, notificationMention, chirpID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeNotificationsRead)
+	if !ok {
+		return
+	}
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var unreadOnly bool
+	switch strings.ToLower(r.URL.Query().Get("unread")) {
+	case "", "false":
+	case "true":
+		unreadOnly = true
+	default:
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid unread")
+		return
+	}
+
+	notifications, err := cfg.db.GetNotifications(context.Background(), database.GetNotificationsParams{
+		UserID:          userID,
+		UnreadOnly:      unreadOnly,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	unreadCount, err := cfg.db.CountUnreadNotifications(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	notifications, nextCursor := paginate(notifications, page, func(n database.Notification) pageCursor {
+		return pageCursor{CreatedAt: n.CreatedAt, ID: n.ID}
+	})
+	sendJSONResponse(w, http.StatusOK, notificationsPage{
+		Notifications: notifications,
+		UnreadCount:   unreadCount,
+		NextCursor:    nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handleMarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeNotificationsWrite)
+	if !ok {
+		return
+	}
+	var body struct {
+		IDs []uuid.UUID `json:"ids"`
+		All bool        `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.All == (len(body.IDs) > 0) {
+		sendErrorResponse(w, http.StatusBadRequest, "Specify either ids or all")
+		return
+	}
+	var err error
+	if body.All {
+		err = cfg.db.MarkAllNotificationsRead(context.Background(), userID)
+	} else {
+		err = cfg.db.MarkNotificationsRead(context.Background(), database.MarkNotificationsReadParams{
+			UserID: userID,
+			Ids:    body.IDs,
+		})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
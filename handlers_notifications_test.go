@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestNotifications(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	handle := "bob"
+	if _, err := cfg.db.UpdateUser(context.Background(), database.UpdateUserParams{ID: bob.ID, Handle: &handle}); err != nil {
+		t.Fatalf("Error setting handle: %v", err)
+	}
+	aliceToken := makeTestToken(t, cfg, alice)
+	bobToken := makeTestToken(t, cfg, bob)
+	bobChirp := createTestChirp(t, cfg, bob, "hello", uuid.NullUUID{})
+
+	rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", aliceToken, map[string]any{"body": "hi @Bob and @nobody"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("mentioning bob = %d %s", rec.Code, rec.Body)
+	}
+	mention := decodeResponse[chirpResponse](t, rec)
+	// Editing without adding a mention doesn't notify again.
+	rec = serve(t, cfg.handleUpdateChirp, "PUT", "/api/chirps/"+mention.ID.String(), aliceToken, map[string]string{"body": "hey @bob"}, "id", mention.ID.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("editing the mention = %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", aliceToken, map[string]any{"body": "a reply", "in_reply_to": bobChirp.ID})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("replying = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, cfg.handleLikeChirp, "POST", "/api/chirps/"+bobChirp.ID.String()+"/like", aliceToken, nil, "id", bobChirp.ID.String()); rec.Code != http.StatusNoContent {
+		t.Fatalf("liking = %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(t, cfg.handleFollowUser, "POST", "/api/users/"+bob.ID.String()+"/follow", aliceToken, nil, "id", bob.ID.String()); rec.Code != http.StatusNoContent {
+		t.Fatalf("following = %d %s", rec.Code, rec.Body)
+	}
+	// Bob's own actions don't notify him.
+	if rec := serve(t, cfg.handleLikeChirp, "POST", "/api/chirps/"+bobChirp.ID.String()+"/like", bobToken, nil, "id", bobChirp.ID.String()); rec.Code != http.StatusNoContent {
+		t.Fatalf("liking own chirp = %d %s", rec.Code, rec.Body)
+	}
+
+	list := func(query string) notificationsPage {
+		t.Helper()
+		rec := serve(t, cfg.handleGetNotifications, "GET", "/api/notifications"+query, bobToken, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/notifications%s = %d %s", query, rec.Code, rec.Body)
+		}
+		return decodeResponse[notificationsPage](t, rec)
+	}
+	page := list("")
+	var types []string
+	for _, n := range page.Notifications {
+		if n.ActorID != alice.ID {
+			t.Errorf("notification %+v is not from alice", n)
+		}
+		types = append(types, n.Type)
+	}
+	want := []string{notificationFollow, notificationLike, notificationReply, notificationMention}
+	if !slices.Equal(types, want) || page.UnreadCount != 4 {
+		t.Fatalf("notifications = %q with %d unread, want %q with 4 unread", types, page.UnreadCount, want)
+	}
+
+	read := func(body any) {
+		t.Helper()
+		if rec := serve(t, cfg.handleMarkNotificationsRead, "POST", "/api/notifications/read", bobToken, body); rec.Code != http.StatusNoContent {
+			t.Fatalf("marking %v read = %d %s", body, rec.Code, rec.Body)
+		}
+	}
+	read(map[string]any{"ids": []uuid.UUID{page.Notifications[0].ID}})
+	if page := list("?unread=true"); len(page.Notifications) != 3 || page.UnreadCount != 3 {
+		t.Errorf("after reading one: %d unread listed, unread_count %d, want 3 and 3", len(page.Notifications), page.UnreadCount)
+	}
+	read(map[string]any{"all": true})
+	if page := list("?unread=true"); len(page.Notifications) != 0 || page.UnreadCount != 0 {
+		t.Errorf("after reading all: %d unread listed, unread_count %d, want none", len(page.Notifications), page.UnreadCount)
+	}
+	if rec := serve(t, cfg.handleMarkNotificationsRead, "POST", "/api/notifications/read", bobToken, map[string]any{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("marking nothing read = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/aleksaelezovic/chirpy/internal/mail"
+	"github.com/aleksaelezovic/chirpy/internal/throttle"
+)
+
+// Reset requests are counted per email and per client IP, whether or not
+// they lead to a mail, so the endpoint can't be used to flood an inbox.
+var (
+	passwordResetEmailPolicy = throttle.Policy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		LockoutAfter: 5,
+		LockoutFor:   time.Hour,
+		Window:       time.Hour,
+	}
+	passwordResetIPPolicy = throttle.Policy{
+		FreeAttempts: 10,
+		BaseDelay:    time.Second,
+		MaxDelay:     10 * time.Minute,
+		LockoutAfter: 50,
+		LockoutFor:   time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+type resetThrottle struct {
+	emails *throttle.Limiter
+	ips    *throttle.Limiter
+}
+
+func newResetThrottle(store throttle.Store) *resetThrottle {
+	return &resetThrottle{
+		emails: throttle.NewLimiter(store, passwordResetEmailPolicy),
+		ips:    throttle.NewLimiter(store, passwordResetIPPolicy),
+	}
+}
+
+func (rt *resetThrottle) keys(r *http.Request, email string) []throttleKey {
+	return []throttleKey{
+		{"email", "password-reset:email:" + strings.ToLower(strings.TrimSpace(email)), rt.emails},
+		{"ip", "password-reset:ip:" + getClientIP(r), rt.ips},
+	}
+}
+
+func (cfg *apiConfig) handleRequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	keys := cfg.resetThrottle.keys(r, body.Email)
+	if !checkThrottle(w, keys, "Too many password reset requests") {
+		return
+	}
+	for _, k := range keys {
+		if _, _, err := k.limiter.Fail(context.Background(), k.key); err != nil {
+			log.Printf("password reset throttle: %v", err)
+		}
+	}
+	// The account is looked up and mailed after responding, so neither the
+	// response nor its timing tells whether the email is registered.
+	cfg.background.Go(func() {
+		user, err := cfg.db.GetUserByEmail(context.Background(), body.Email)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				log.Printf("password reset: looking up user: %v", err)
+			}
+			return
+		}
+		if err := cfg.sendPasswordReset(context.Background(), user); err != nil {
+			log.Printf("password reset: %v", err)
+		}
+	})
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte{})
+}
+
+// sendPasswordReset replaces any outstanding reset tokens for user with a
+// new one and mails it to them.
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, user database.User) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := qtx.DeletePasswordResetTokens(ctx, user.ID); err != nil {
+		return err
+	}
+	err = qtx.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	link := cfg.publicURL + "/reset-password?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"To choose a new password, open this link within %v:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n", passwordResetTTL, link),
+	})
+}
+
+func (cfg *apiConfig) handleConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Password == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid password")
+		return
+	}
+	hashedPassword, err := auth.HashPassword(body.Password)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	userID, err := qtx.UsePasswordResetToken(context.Background(), auth.HashToken(body.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid or expired token")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	_, err = qtx.UpdateUser(context.Background(), database.UpdateUserParams{
+		ID:             userID,
+		HashedPassword: sql.NullString{String: hashedPassword, Valid: true},
+	})
+	if err == nil {
+		// Whoever might have had the old password is signed out everywhere.
+		err = qtx.RevokeAllRefreshTokens(context.Background(), userID)
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+)
+
+func TestPasswordReset(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	mailer := &recordingMailer{}
+	cfg.mailer = mailer
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+
+	rec := serve(t, cfg.handleRequestPasswordReset, "POST", "/api/password-reset/request", "", map[string]string{"email": "nobody@example.com"})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("reset for an unknown email = %d, want 202", rec.Code)
+	}
+	if sent := mailer.sent(cfg); len(sent) != 0 {
+		t.Fatalf("reset for an unknown email sent %d messages, want 0", len(sent))
+	}
+
+	rec = serve(t, cfg.handleRequestPasswordReset, "POST", "/api/password-reset/request", "", map[string]string{"email": user.Email})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("reset = %d, want 202", rec.Code)
+	}
+	sent := mailer.sent(cfg)
+	if len(sent) != 1 || sent[0].To != user.Email {
+		t.Fatalf("reset sent %+v, want one message to %s", sent, user.Email)
+	}
+	token := tokenFromLink(t, sent[0].Body)
+
+	confirm := map[string]string{"token": token, "password": "newpassword123"}
+	rec = serve(t, cfg.handleConfirmPasswordReset, "POST", "/api/password-reset/confirm", "", confirm)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("confirm = %d %s, want 204", rec.Code, rec.Body)
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Error getting user: %v", err)
+	}
+	if ok, err := auth.VerifyPassword("newpassword123", user.HashedPassword); err != nil || !ok {
+		t.Errorf("new password doesn't match: %v, %v", ok, err)
+	}
+	rec = serve(t, cfg.handleConfirmPasswordReset, "POST", "/api/password-reset/confirm", "", confirm)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("reusing the token = %d, want 400", rec.Code)
+	}
+}
+
+func TestPasswordResetThrottle(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	mailer := &recordingMailer{}
+	cfg.mailer = mailer
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+
+	for i := range passwordResetEmailPolicy.FreeAttempts {
+		rec := serve(t, cfg.handleRequestPasswordReset, "POST", "/api/password-reset/request", "", map[string]string{"email": user.Email})
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d = %d, want 202", i+1, rec.Code)
+		}
+	}
+	rec := serve(t, cfg.handleRequestPasswordReset, "POST", "/api/password-reset/request", "", map[string]string{"email": " USER@example.com"})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request over the limit = %d, Retry-After %q, want 429 and a delay", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if sent := mailer.sent(cfg); len(sent) != passwordResetEmailPolicy.FreeAttempts {
+		t.Errorf("sent %d messages, want %d", len(sent), passwordResetEmailPolicy.FreeAttempts)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// publicProfile is what anyone can see about a user. It deliberately leaves
+// out the email address.
+type publicProfile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      *string   `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func toPublicProfile(user database.User) publicProfile {
+	return publicProfile{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+}
+
+func (cfg *apiConfig) handleGetUserProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, toPublicProfile(user))
+}
+
+func (cfg *apiConfig) handleGetUserByHandle(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.db.GetUserByHandle(context.Background(), r.PathValue("handle"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, toPublicProfile(user))
+}
+
+// handleGetUserRelation serves GET /api/users/{id}/{relation}. ServeMux
+// cannot tell /api/users/by-handle/{handle} apart from routes such as
+// /api/users/{id}/followers, so they share one pattern and are told apart
+// here.
+func (cfg *apiConfig) handleGetUserRelation(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("id") == "by-handle" {
+		r.SetPathValue("handle", r.PathValue("relation"))
+		cfg.handleGetUserByHandle(w, r)
+		return
+	}
+	switch r.PathValue("relation") {
+	case "followers":
+		cfg.handleGetFollowers(w, r)
+	case "following":
+		cfg.handleGetFollowing(w, r)
+	case "likes":
+		cfg.handleGetUserLikes(w, r)
+	default:
+		sendErrorResponse(w, http.StatusNotFound, "Not found")
+	}
+}
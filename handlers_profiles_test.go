@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPublicProfiles(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	update := func(token string, body map[string]string) int {
+		t.Helper()
+		return serve(t, cfg.handleUpdateCredentials, "PUT", "/api/users", token, body).Code
+	}
+	aliceToken := makeTestToken(t, cfg, alice)
+	if got := update(aliceToken, map[string]string{"handle": "@Alice_1", "display_name": "Alice", "bio": "Hi!"}); got != http.StatusOK {
+		t.Fatalf("setting alice's profile = %d, want 200", got)
+	}
+	for _, tc := range []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"taken handle", map[string]string{"handle": "alice_1"}, http.StatusConflict},
+		{"short handle", map[string]string{"handle": "ab"}, http.StatusBadRequest},
+		{"long bio", map[string]string{"bio": strings.Repeat("a", maxBioLength+1)}, http.StatusBadRequest},
+		{"long display name", map[string]string{"display_name": strings.Repeat("a", maxDisplayNameLength+1)}, http.StatusBadRequest},
+	} {
+		if got := update(makeTestToken(t, cfg, bob), tc.body); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		serve(t, cfg.handleGetUserProfile, "GET", "/api/users/"+alice.ID.String(), "", nil, "id", alice.ID.String()),
+		serve(t, cfg.handleGetUserRelation, "GET", "/api/users/by-handle/ALICE_1", "", nil, "id", "by-handle", "relation", "ALICE_1"),
+	} {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("profile lookup = %d %s", rec.Code, rec.Body)
+		}
+		if strings.Contains(rec.Body.String(), "alice@example.com") {
+			t.Errorf("public profile exposes the email: %s", rec.Body)
+		}
+		profile := decodeResponse[publicProfile](t, rec)
+		if profile.ID != alice.ID || profile.Handle == nil || *profile.Handle != "Alice_1" || profile.DisplayName != "Alice" || profile.Bio != "Hi!" {
+			t.Errorf("profile = %+v, want alice's", profile)
+		}
+	}
+	rec := serve(t, cfg.handleGetUserRelation, "GET", "/api/users/by-handle/nobody", "", nil, "id", "by-handle", "relation", "nobody")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown handle = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleRechirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok || !cfg.checkCanPost(w, userID) {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	original, err := getOriginalChirpForUpdate(context.Background(), qtx, userID, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	status := http.StatusCreated
+	rechirp, err := qtx.CreateRechirp(context.Background(), database.CreateRechirpParams{
+		UserID:    userID,
+		RechirpOf: original.ID,
+	})
+	if err == sql.ErrNoRows {
+		// Already rechirped: hand back the existing one.
+		status = http.StatusOK
+		rechirp, err = qtx.GetRechirp(context.Background(), database.GetRechirpParams{
+			UserID:    userID,
+			RechirpOf: original.ID,
+		})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	responses, err := cfg.toChirpResponses(context.Background(), uuid.NullUUID{}, []database.Chirp{rechirp})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, status, responses[0])
+}
+
+func (cfg *apiConfig) handleUndoRechirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+	err = cfg.db.DeleteRechirp(context.Background(), database.DeleteRechirpParams{
+		UserID:    userID,
+		RechirpOf: id,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
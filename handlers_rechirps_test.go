@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRechirpAndQuote(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	original := createTestChirp(t, cfg, alice, "worth sharing", uuid.NullUUID{})
+	bobToken := makeTestToken(t, cfg, bob)
+	rechirp := func(id uuid.UUID, want int) chirpResponse {
+		t.Helper()
+		target := "/api/chirps/" + id.String() + "/rechirp"
+		rec := serve(t, cfg.handleRechirp, "POST", target, bobToken, nil, "id", id.String())
+		if rec.Code != want {
+			t.Fatalf("POST %s = %d %s, want %d", target, rec.Code, rec.Body, want)
+		}
+		return decodeResponse[chirpResponse](t, rec)
+	}
+
+	first := rechirp(original.ID, http.StatusCreated)
+	if !first.RechirpOf.Valid || first.RechirpOf.UUID != original.ID || first.Original == nil || first.Original.Body != original.Body {
+		t.Errorf("rechirp = %+v, want a rechirp of %s carrying the original", first, original.ID)
+	}
+	// Rechirping again, or rechirping the rechirp, hands back the same one.
+	for _, id := range []uuid.UUID{original.ID, first.ID} {
+		if again := rechirp(id, http.StatusOK); again.ID != first.ID {
+			t.Errorf("rechirping %s again = %s, want %s", id, again.ID, first.ID)
+		}
+	}
+
+	rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", bobToken, map[string]any{"body": "so true", "quote_of": first.ID})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("quoting = %d %s", rec.Code, rec.Body)
+	}
+	quote := decodeResponse[chirpResponse](t, rec)
+	if !quote.QuoteOf.Valid || quote.QuoteOf.UUID != original.ID || quote.Original == nil || quote.Original.ID != original.ID {
+		t.Errorf("quote = %+v, want a quote of %s carrying the original", quote, original.ID)
+	}
+
+	target := "/api/chirps/" + original.ID.String() + "/rechirp"
+	if rec := serve(t, cfg.handleUndoRechirp, "DELETE", target, bobToken, nil, "id", original.ID.String()); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE %s = %d %s", target, rec.Code, rec.Body)
+	}
+	rec = serve(t, cfg.handleGetChirps, "GET", "/api/chirps", "", nil)
+	got := chirpIDs(decodeResponse[[]chirpResponse](t, rec))
+	if want := []uuid.UUID{original.ID, quote.ID}; !slices.Equal(got, want) {
+		t.Errorf("chirps after undoing the rechirp = %v, want %v", got, want)
+	}
+}
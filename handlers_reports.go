@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+const (
+	maxReportDetailsLength  = 500
+	maxResolutionNoteLength = 500
+	maxSuspendDays          = 365
+)
+
+// Report statuses and the actions a moderator can take on a report.
+const (
+	reportOpen      = "open"
+	reportDismissed = "dismissed"
+	reportActioned  = "actioned"
+
+	reportActionHideChirp     = "hide_chirp"
+	reportActionSuspendAuthor = "suspend_author"
+)
+
+func (cfg *apiConfig) handleReportChirp(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+	var body struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !slices.Contains(reportReasons, body.Reason) {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid reason")
+		return
+	}
+	if len(body.Details) > maxReportDetailsLength {
+		sendErrorResponse(w, http.StatusBadRequest, "Details are too long")
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpByID(context.Background(), id)
+	if err == nil && chirp.RechirpOf.Valid {
+		chirp, err = cfg.db.GetChirpByID(context.Background(), chirp.RechirpOf.UUID)
+	}
+	if err == nil && chirp.DeletedAt != nil {
+		err = sql.ErrNoRows
+	}
+	// Blocks don't stop reports: people often want to report whoever made
+	// them block someone.
+	if err == nil {
+		err = checkNotShadowBanned(context.Background(), cfg.db, uuid.NullUUID{UUID: userID, Valid: true}, chirp)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if chirp.UserID == userID {
+		sendErrorResponse(w, http.StatusBadRequest, "Cannot report your own chirp")
+		return
+	}
+	report, err := cfg.db.CreateReport(context.Background(), database.CreateReportParams{
+		ChirpID:       uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ChirpAuthorID: chirp.UserID,
+		ChirpBody:     chirp.Body,
+		ReporterID:    userID,
+		Reason:        body.Reason,
+		Details:       body.Details,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusConflict, "Chirp already reported")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusCreated, report)
+}
+
+func (cfg *apiConfig) handleGetOpenReports(w http.ResponseWriter, r *http.Request) {
+	page, err := getPageParams(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reports, err := cfg.db.GetOpenReports(context.Background(), database.GetOpenReportsParams{
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		Limit:           page.queryLimit(),
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	reports, nextCursor := paginate(reports, page, func(report database.Report) pageCursor {
+		return pageCursor{CreatedAt: report.CreatedAt, ID: report.ID}
+	})
+	sendJSONResponse(w, http.StatusOK, struct {
+		Reports    []database.Report `json:"reports"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+	}{
+		Reports:    reports,
+		NextCursor: nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handleDismissReport(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cfg.resolveReport(w, r, reportDecision{Status: reportDismissed, Note: body.Note})
+}
+
+func (cfg *apiConfig) handleActOnReport(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Action      string `json:"action"`
+		SuspendDays int    `json:"suspend_days"`
+		Note        string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	decision := reportDecision{Status: reportActioned, Action: body.Action, Note: body.Note}
+	switch body.Action {
+	case reportActionHideChirp:
+	case reportActionSuspendAuthor:
+		if body.SuspendDays < 1 || body.SuspendDays > maxSuspendDays {
+			sendErrorResponse(w, http.StatusBadRequest, "suspend_days must be between 1 and 365")
+			return
+		}
+		decision.SuspendFor = time.Duration(body.SuspendDays) * 24 * time.Hour
+	default:
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid action")
+		return
+	}
+	cfg.resolveReport(w, r, decision)
+}
+
+type reportDecision struct {
+	Status     string
+	Action     string
+	SuspendFor time.Duration
+	Note       string
+}
+
+// resolveReport closes the report named in the path. Acting on a report
+// closes every other open report about the same chirp too, since they have
+// all been dealt with. The decision is kept on the report and in the audit
+// log.
+func (cfg *apiConfig) resolveReport(w http.ResponseWriter, r *http.Request, decision reportDecision) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(decision.Note) > maxResolutionNoteLength {
+		sendErrorResponse(w, http.StatusBadRequest, "Note is too long")
+		return
+	}
+	moderator := getRolePrincipal(r)
+	moderatorID := moderator.UserID
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, err := qtx.GetReportForUpdate(context.Background(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if report.Status != reportOpen {
+		sendErrorResponse(w, http.StatusConflict, "Report already resolved")
+		return
+	}
+
+	chirpID := report.ChirpID
+	var resolution *string
+	if decision.Action != "" {
+		resolution = &decision.Action
+	}
+	// The other reports are resolved before acting, because hiding the
+	// chirp may delete it, which clears chirp_id on every report about it.
+	if decision.Status == reportActioned && chirpID.Valid {
+		err = qtx.ResolveChirpReports(context.Background(), database.ResolveChirpReportsParams{
+			ChirpID:        chirpID,
+			Status:         decision.Status,
+			Resolution:     resolution,
+			ResolutionNote: decision.Note,
+			ResolvedBy:     uuid.NullUUID{UUID: moderatorID, Valid: true},
+		})
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	var suspendedUntil *time.Time
+	switch decision.Action {
+	case reportActionHideChirp:
+		err = hideReportedChirp(context.Background(), qtx, report)
+	case reportActionSuspendAuthor:
+		var author database.User
+		if author, err = qtx.GetUserByID(context.Background(), report.ChirpAuthorID); err != nil {
+			break
+		}
+		if roleRanks[author.Role] >= roleRanks[moderator.Role] {
+			sendErrorResponse(w, http.StatusForbidden, "Cannot suspend a user whose role is not below yours")
+			return
+		}
+		// Stored in UTC because the column has no time zone.
+		author, err = qtx.SuspendUser(context.Background(), database.SuspendUserParams{
+			ID:             report.ChirpAuthorID,
+			SuspendedUntil: time.Now().Add(decision.SuspendFor).UTC(),
+		})
+		if err == nil {
+			suspendedUntil = author.SuspendedUntil
+			err = qtx.RevokeAllRefreshTokens(context.Background(), report.ChirpAuthorID)
+		}
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	report, err = qtx.ResolveReport(context.Background(), database.ResolveReportParams{
+		ID:             report.ID,
+		Status:         decision.Status,
+		Resolution:     resolution,
+		ResolutionNote: decision.Note,
+		ResolvedBy:     uuid.NullUUID{UUID: moderatorID, Valid: true},
+	})
+	if err == nil {
+		err = recordAuditEvent(context.Background(), qtx, auditReportResolved, uuid.NullUUID{UUID: moderatorID, Valid: true}, getClientIP(r), map[string]any{
+			"report_id":       report.ID,
+			"chirp_id":        chirpID,
+			"chirp_author_id": report.ChirpAuthorID,
+			"status":          report.Status,
+			"resolution":      report.Resolution,
+			"suspended_until": suspendedUntil,
+			"note":            report.ResolutionNote,
+		})
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, report)
+}
+
+// hideReportedChirp removes the chirp a report is about, as if its author
+// had deleted it. The report keeps a copy of the body.
+func hideReportedChirp(ctx context.Context, q *database.Queries, report database.Report) error {
+	if !report.ChirpID.Valid {
+		return nil
+	}
+	chirp, err := q.GetChirpByIDForUpdate(ctx, report.ChirpID.UUID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil || chirp.DeletedAt != nil {
+		return err
+	}
+	return removeChirp(ctx, q, chirp)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestHideChirpResolvesEveryReport(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	author := createTestUser(t, cfg, "author@example.com", roleUser)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	moderator := createTestUser(t, cfg, "moderator@example.com", roleModerator)
+	chirp := createTestChirp(t, cfg, author, "something rude", uuid.NullUUID{})
+
+	var reports []database.Report
+	for _, reporter := range []database.User{alice, bob} {
+		rec := serve(t, cfg.handleReportChirp, "POST", "/api/chirps/"+chirp.ID.String()+"/report", makeTestToken(t, cfg, reporter), map[string]string{"reason": "harassment"}, "id", chirp.ID.String())
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("reporting as %s = %d %s", reporter.Email, rec.Code, rec.Body)
+		}
+		reports = append(reports, decodeResponse[database.Report](t, rec))
+	}
+
+	// The chirp has no replies, so hiding it deletes it outright.
+	moderatorToken := makeTestToken(t, cfg, moderator)
+	rec := serve(t, cfg.requireRole(roleModerator, cfg.handleActOnReport), "POST", "/admin/reports/"+reports[0].ID.String()+"/action", moderatorToken, map[string]string{"action": reportActionHideChirp}, "id", reports[0].ID.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("acting on report = %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, cfg.handleGetChirpByID, "GET", "/api/chirps/"+chirp.ID.String(), "", nil, "id", chirp.ID.String())
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET hidden chirp = %d, want 404", rec.Code)
+	}
+
+	rec = serve(t, cfg.requireRole(roleModerator, cfg.handleGetOpenReports), "GET", "/admin/reports", moderatorToken, nil)
+	open := decodeResponse[struct {
+		Reports []database.Report `json:"reports"`
+	}](t, rec).Reports
+	if len(open) != 0 {
+		t.Errorf("open reports after hiding the chirp = %d, want 0", len(open))
+	}
+	rec = serve(t, cfg.requireRole(roleModerator, cfg.handleDismissReport), "POST", "/admin/reports/"+reports[1].ID.String()+"/dismiss", moderatorToken, map[string]string{}, "id", reports[1].ID.String())
+	if rec.Code != http.StatusConflict {
+		t.Errorf("dismissing the other report = %d, want 409", rec.Code)
+	}
+}
+
+func TestReportChirp(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	author := createTestUser(t, cfg, "author@example.com", roleUser)
+	reporter := createTestUser(t, cfg, "reporter@example.com", roleUser)
+	chirp := createTestChirp(t, cfg, author, "spammy", uuid.NullUUID{})
+	report := func(user database.User, id uuid.UUID, body map[string]string) int {
+		t.Helper()
+		return serve(t, cfg.handleReportChirp, "POST", "/api/chirps/"+id.String()+"/report", makeTestToken(t, cfg, user), body, "id", id.String()).Code
+	}
+
+	for _, tc := range []struct {
+		name string
+		user database.User
+		id   uuid.UUID
+		body map[string]string
+		want int
+	}{
+		{"unknown reason", reporter, chirp.ID, map[string]string{"reason": "boring"}, http.StatusBadRequest},
+		{"own chirp", author, chirp.ID, map[string]string{"reason": "spam"}, http.StatusBadRequest},
+		{"missing chirp", reporter, uuid.New(), map[string]string{"reason": "spam"}, http.StatusNotFound},
+		{"report", reporter, chirp.ID, map[string]string{"reason": "spam", "details": "buy now"}, http.StatusCreated},
+		{"second report", reporter, chirp.ID, map[string]string{"reason": "other"}, http.StatusConflict},
+	} {
+		if got := report(tc.user, tc.id, tc.body); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestResolveReports(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	author := createTestUser(t, cfg, "author@example.com", roleUser)
+	admin := createTestUser(t, cfg, "admin@example.com", roleAdmin)
+	reporter := createTestUser(t, cfg, "reporter@example.com", roleUser)
+	moderator := createTestUser(t, cfg, "moderator@example.com", roleModerator)
+	moderatorToken := makeTestToken(t, cfg, moderator)
+	authorLogin := loginTestUser(t, cfg, author, "test")
+	report := func(chirp database.Chirp) database.Report {
+		t.Helper()
+		rec := serve(t, cfg.handleReportChirp, "POST", "/api/chirps/"+chirp.ID.String()+"/report", makeTestToken(t, cfg, reporter), map[string]string{"reason": "spam"}, "id", chirp.ID.String())
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("reporting = %d %s", rec.Code, rec.Body)
+		}
+		return decodeResponse[database.Report](t, rec)
+	}
+	resolve := func(handler http.HandlerFunc, verb string, report database.Report, body map[string]any) *httptest.ResponseRecorder {
+		t.Helper()
+		target := "/admin/reports/" + report.ID.String() + "/" + verb
+		return serve(t, cfg.requireRole(roleModerator, handler), "POST", target, moderatorToken, body, "id", report.ID.String())
+	}
+	dismissed := report(createTestChirp(t, cfg, author, "first", uuid.NullUUID{}))
+	suspended := report(createTestChirp(t, cfg, author, "second", uuid.NullUUID{}))
+	byAdmin := report(createTestChirp(t, cfg, admin, "third", uuid.NullUUID{}))
+
+	rec := resolve(cfg.handleDismissReport, "dismiss", dismissed, map[string]any{"note": "fine"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("dismissing = %d %s", rec.Code, rec.Body)
+	}
+	if got := decodeResponse[database.Report](t, rec); got.Status != reportDismissed || got.Resolution != nil || got.ResolutionNote != "fine" {
+		t.Errorf("dismissed report = %+v", got)
+	}
+
+	for _, body := range []map[string]any{
+		{"action": "ban_forever"},
+		{"action": reportActionSuspendAuthor, "suspend_days": 0},
+	} {
+		if rec := resolve(cfg.handleActOnReport, "action", suspended, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("acting with %v = %d, want 400", body, rec.Code)
+		}
+	}
+	rec = resolve(cfg.handleActOnReport, "action", suspended, map[string]any{"action": reportActionSuspendAuthor, "suspend_days": 7})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("suspending the author = %d %s", rec.Code, rec.Body)
+	}
+	if got := decodeResponse[database.Report](t, rec); got.Status != reportActioned || got.Resolution == nil || *got.Resolution != reportActionSuspendAuthor {
+		t.Errorf("actioned report = %+v", got)
+	}
+	if rec := serve(t, cfg.handleRefreshToken, "POST", "/api/refresh", authorLogin.RefreshToken, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("suspended author refreshing = %d, want 401", rec.Code)
+	}
+	if rec := resolve(cfg.handleActOnReport, "action", byAdmin, map[string]any{"action": reportActionSuspendAuthor, "suspend_days": 7}); rec.Code != http.StatusForbidden {
+		t.Errorf("moderator suspending an admin = %d, want 403", rec.Code)
+	}
+
+	rec = serve(t, cfg.requireRole(roleModerator, cfg.handleGetOpenReports), "GET", "/admin/reports", moderatorToken, nil)
+	open := decodeResponse[struct {
+		Reports []database.Report `json:"reports"`
+	}](t, rec).Reports
+	if len(open) != 1 || open[0].ID != byAdmin.ID {
+		t.Errorf("open reports = %+v, want only the one about the admin", open)
+	}
+}
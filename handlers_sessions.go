@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// A session is one login: the chain of refresh tokens rotated from it share
+// a family ID, which doubles as the session ID.
+
+func (cfg *apiConfig) handleGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	sessions, err := cfg.db.GetSessions(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if sessions == nil {
+		sessions = []database.GetSessionsRow{}
+	}
+	sendJSONResponse(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	revoked, err := cfg.db.RevokeSession(context.Background(), database.RevokeSessionParams{
+		FamilyID: id,
+		UserID:   userID,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if revoked == 0 {
+		sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	if err := cfg.db.RevokeAllRefreshTokens(context.Background(), userID); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+func TestSessions(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	other := createTestUser(t, cfg, "other@example.com", roleUser)
+	laptop := loginTestUser(t, cfg, user, "laptop")
+	phone := loginTestUser(t, cfg, user, "phone")
+	tablet := loginTestUser(t, cfg, user, "tablet")
+	token := makeTestToken(t, cfg, user)
+	sessions := func() map[string]database.GetSessionsRow {
+		t.Helper()
+		rec := serve(t, cfg.handleGetSessions, "GET", "/api/sessions", token, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/sessions = %d %s", rec.Code, rec.Body)
+		}
+		byAgent := map[string]database.GetSessionsRow{}
+		for _, s := range decodeResponse[[]database.GetSessionsRow](t, rec) {
+			byAgent[s.UserAgent] = s
+		}
+		return byAgent
+	}
+	refreshes := func(login loginResponse) bool {
+		t.Helper()
+		return serve(t, cfg.handleRefreshToken, "POST", "/api/refresh", login.RefreshToken, nil).Code == http.StatusOK
+	}
+
+	// Rotating a refresh token keeps it the same session.
+	if !refreshes(laptop) {
+		t.Fatal("refreshing the laptop session failed")
+	}
+	listed := sessions()
+	if len(listed) != 3 {
+		t.Fatalf("sessions = %+v, want laptop, phone and tablet", listed)
+	}
+
+	phoneID := listed["phone"].ID.String()
+	target := "/api/sessions/" + phoneID
+	if rec := serve(t, cfg.handleRevokeSession, "DELETE", target, makeTestToken(t, cfg, other), nil, "id", phoneID); rec.Code != http.StatusNotFound {
+		t.Errorf("revoking someone else's session = %d, want 404", rec.Code)
+	}
+	if rec := serve(t, cfg.handleRevokeSession, "DELETE", target, token, nil, "id", phoneID); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE %s = %d %s", target, rec.Code, rec.Body)
+	}
+	if refreshes(phone) {
+		t.Error("the revoked phone session can still refresh")
+	}
+	if _, ok := sessions()["phone"]; ok {
+		t.Error("the revoked phone session is still listed")
+	}
+
+	if rec := serve(t, cfg.handleRevokeAllSessions, "POST", "/api/sessions/revoke-all", token, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("POST /api/sessions/revoke-all = %d %s", rec.Code, rec.Body)
+	}
+	if refreshes(tablet) {
+		t.Error("the tablet session can still refresh after revoking all")
+	}
+	if listed := sessions(); len(listed) != 0 {
+		t.Errorf("sessions after revoking all = %+v, want none", listed)
+	}
+}
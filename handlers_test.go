@@ -0,0 +1,615 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/aleksaelezovic/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+func TestGetChirpsPagination(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	// More than one page even at the largest limit.
+	const total = maxPageLimit + 5
+	for i := range total {
+		createTestChirp(t, cfg, alice, fmt.Sprintf("chirp %d", i), uuid.NullUUID{})
+	}
+
+	// Clients that don't paginate still get every chirp in a bare array.
+	rec := serve(t, cfg.handleGetChirps, "GET", "/api/chirps", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/chirps = %d %s", rec.Code, rec.Body)
+	}
+	all := chirpIDs(decodeResponse[[]chirpResponse](t, rec))
+	if len(all) != total {
+		t.Fatalf("GET /api/chirps = %d chirps, want %d", len(all), total)
+	}
+
+	var paged []uuid.UUID
+	target := "/api/chirps?limit=50"
+	for pages := 1; ; pages++ {
+		rec := serve(t, cfg.handleGetChirps, "GET", target, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", target, rec.Code, rec.Body)
+		}
+		page := decodeResponse[chirpsPage](t, rec)
+		if len(page.Chirps) > 50 {
+			t.Fatalf("GET %s returned %d chirps, want at most 50", target, len(page.Chirps))
+		}
+		paged = append(paged, chirpIDs(page.Chirps)...)
+		if page.NextCursor == "" {
+			if pages != 3 {
+				t.Errorf("got %d pages, want 3", pages)
+			}
+			break
+		}
+		target = "/api/chirps?limit=50&cursor=" + page.NextCursor
+	}
+	if !slices.Equal(paged, all) {
+		t.Errorf("paged chirps = %v, want %v", paged, all)
+	}
+
+	rec = serve(t, cfg.handleGetChirps, "GET", "/api/chirps?sort=desc&limit=1", "", nil)
+	page := decodeResponse[chirpsPage](t, rec)
+	if len(page.Chirps) != 1 || page.Chirps[0].ID != all[len(all)-1] {
+		t.Errorf("GET /api/chirps?sort=desc&limit=1 = %v, want the newest chirp %v", chirpIDs(page.Chirps), all[len(all)-1])
+	}
+
+	rec = serve(t, cfg.handleGetChirps, "GET", "/api/chirps?cursor=garbage", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/chirps?cursor=garbage = %d, want 400", rec.Code)
+	}
+}
+
+func TestShadowBannedChirpsHidden(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	aliceChirp := createTestChirp(t, cfg, alice, "hello from alice", uuid.NullUUID{})
+	bobChirp := createTestChirp(t, cfg, bob, "hello from bob", uuid.NullUUID{})
+	bobReply := createTestChirp(t, cfg, bob, "hello back", uuid.NullUUID{UUID: aliceChirp.ID, Valid: true})
+	now := time.Now().UTC()
+	if _, err := cfg.db.SetAccountState(context.Background(), database.SetAccountStateParams{ID: bob.ID, ShadowBannedAt: &now}); err != nil {
+		t.Fatalf("Error shadow-banning bob: %v", err)
+	}
+	aliceToken := makeTestToken(t, cfg, alice)
+	bobToken := makeTestToken(t, cfg, bob)
+
+	for _, tc := range []struct {
+		viewer string
+		token  string
+		want   []uuid.UUID
+	}{
+		{"anonymous", "", []uuid.UUID{aliceChirp.ID}},
+		{"alice", aliceToken, []uuid.UUID{aliceChirp.ID}},
+		{"bob", bobToken, []uuid.UUID{aliceChirp.ID, bobChirp.ID, bobReply.ID}},
+	} {
+		rec := serve(t, cfg.handleGetChirps, "GET", "/api/chirps", tc.token, nil)
+		if got := chirpIDs(decodeResponse[[]chirpResponse](t, rec)); !slices.Equal(got, tc.want) {
+			t.Errorf("GET /api/chirps as %s = %v, want %v", tc.viewer, got, tc.want)
+		}
+
+		rec = serve(t, cfg.handleSearchChirps, "GET", "/api/chirps/search?q=hello", tc.token, nil)
+		got := chirpIDs(decodeResponse[chirpsPage](t, rec).Chirps)
+		slices.SortFunc(got, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+		want := slices.Clone(tc.want)
+		slices.SortFunc(want, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+		if !slices.Equal(got, want) {
+			t.Errorf("search as %s = %v, want %v", tc.viewer, got, want)
+		}
+	}
+
+	rec := serve(t, cfg.handleGetChirpByID, "GET", "/api/chirps/"+bobChirp.ID.String(), aliceToken, nil, "id", bobChirp.ID.String())
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET bob's chirp as alice = %d, want 404", rec.Code)
+	}
+	rec = serve(t, cfg.handleGetChirpByID, "GET", "/api/chirps/"+bobChirp.ID.String(), bobToken, nil, "id", bobChirp.ID.String())
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET bob's chirp as bob = %d, want 200", rec.Code)
+	}
+	rec = serve(t, cfg.handleGetChirpThread, "GET", "/api/chirps/"+bobChirp.ID.String()+"/thread", aliceToken, nil, "id", bobChirp.ID.String())
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET bob's thread as alice = %d, want 404", rec.Code)
+	}
+
+	for _, tc := range []struct {
+		viewer  string
+		token   string
+		replies int
+	}{
+		{"alice", aliceToken, 0},
+		{"bob", bobToken, 1},
+	} {
+		rec := serve(t, cfg.handleGetChirpThread, "GET", "/api/chirps/"+aliceChirp.ID.String()+"/thread", tc.token, nil, "id", aliceChirp.ID.String())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET alice's thread as %s = %d %s", tc.viewer, rec.Code, rec.Body)
+		}
+		if got := len(decodeResponse[chirpThread](t, rec).Replies); got != tc.replies {
+			t.Errorf("alice's thread as %s has %d replies, want %d", tc.viewer, got, tc.replies)
+		}
+	}
+}
+
+func TestBlocksAndMutesHideChirps(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	carol := createTestUser(t, cfg, "carol@example.com", roleUser)
+	aliceChirp := createTestChirp(t, cfg, alice, "hello from alice", uuid.NullUUID{})
+	bobChirp := createTestChirp(t, cfg, bob, "hello from bob", uuid.NullUUID{})
+	carolChirp := createTestChirp(t, cfg, carol, "hello from carol", uuid.NullUUID{})
+	createTestChirp(t, cfg, bob, "hello back from bob", uuid.NullUUID{UUID: aliceChirp.ID, Valid: true})
+	createTestChirp(t, cfg, carol, "hello back from carol", uuid.NullUUID{UUID: aliceChirp.ID, Valid: true})
+	aliceToken := makeTestToken(t, cfg, alice)
+	bobToken := makeTestToken(t, cfg, bob)
+
+	rec := serve(t, cfg.handleBlockUser, "POST", "/api/users/"+bob.ID.String()+"/block", aliceToken, nil, "id", bob.ID.String())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("alice blocking bob = %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, cfg.handleMuteUser, "POST", "/api/users/"+carol.ID.String()+"/mute", aliceToken, nil, "id", carol.ID.String())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("alice muting carol = %d %s", rec.Code, rec.Body)
+	}
+
+	// Alice sees neither bob nor carol; bob doesn't see alice, because
+	// blocks work both ways.
+	rec = serve(t, cfg.handleGetChirps, "GET", "/api/chirps", aliceToken, nil)
+	if got := chirpIDs(decodeResponse[[]chirpResponse](t, rec)); !slices.Equal(got, []uuid.UUID{aliceChirp.ID}) {
+		t.Errorf("GET /api/chirps as alice = %v, want only %v", got, aliceChirp.ID)
+	}
+	rec = serve(t, cfg.handleGetChirps, "GET", "/api/chirps", bobToken, nil)
+	for _, chirp := range decodeResponse[[]chirpResponse](t, rec) {
+		if chirp.UserID == alice.ID {
+			t.Errorf("GET /api/chirps as bob includes alice's chirp %v", chirp.ID)
+		}
+	}
+	rec = serve(t, cfg.handleSearchChirps, "GET", "/api/chirps/search?q=hello", aliceToken, nil)
+	for _, chirp := range decodeResponse[chirpsPage](t, rec).Chirps {
+		if chirp.UserID != alice.ID {
+			t.Errorf("search as alice includes %v by %v", chirp.ID, chirp.UserID)
+		}
+	}
+	rec = serve(t, cfg.handleGetChirpThread, "GET", "/api/chirps/"+aliceChirp.ID.String()+"/thread", aliceToken, nil, "id", aliceChirp.ID.String())
+	if replies := decodeResponse[chirpThread](t, rec).Replies; len(replies) != 0 {
+		t.Errorf("alice's thread as alice has %d replies, want 0", len(replies))
+	}
+
+	// Blocked chirps can't be fetched directly; muted ones can.
+	rec = serve(t, cfg.handleGetChirpByID, "GET", "/api/chirps/"+bobChirp.ID.String(), aliceToken, nil, "id", bobChirp.ID.String())
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET bob's chirp as alice = %d, want 404", rec.Code)
+	}
+	rec = serve(t, cfg.handleGetChirpByID, "GET", "/api/chirps/"+carolChirp.ID.String(), aliceToken, nil, "id", carolChirp.ID.String())
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET carol's chirp as alice = %d, want 200", rec.Code)
+	}
+
+	rec = serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", bobToken, map[string]any{
+		"body":        "let me in",
+		"in_reply_to": aliceChirp.ID,
+	})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("bob replying to alice = %d, want 403", rec.Code)
+	}
+}
+
+// sendPolkaWebhook delivers body to the webhook handler, signed with secret
+// unless it is nil.
+func sendPolkaWebhook(t *testing.T, cfg *apiConfig, secret []byte, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(body))
+	if secret != nil {
+		req.Header.Set("Polka-Signature", webhook.Sign(secret, time.Now(), []byte(body)))
+	}
+	rec := httptest.NewRecorder()
+	cfg.handlePolkaWebhook(rec, req)
+	return rec
+}
+
+func TestPolkaWebhookAuthentication(t *testing.T) {
+	secret := []byte("whsec_test")
+	cfg := newTestConfig(t)
+	cfg.polkaVerifier = webhook.NewVerifier(webhook.DefaultTolerance, secret)
+
+	for _, tc := range []struct {
+		name   string
+		secret []byte
+		body   string
+		want   int
+	}{
+		{"unsigned", nil, `{"id":"evt_1","event":"user.upgraded"}`, http.StatusUnauthorized},
+		{"wrong secret", []byte("whsec_other"), `{"id":"evt_1","event":"user.upgraded"}`, http.StatusUnauthorized},
+		{"other event", secret, `{"id":"evt_1","event":"user.downgraded"}`, http.StatusNoContent},
+		{"missing id", secret, `{"event":"user.upgraded"}`, http.StatusBadRequest},
+		{"other event without id", secret, `{"event":"user.downgraded"}`, http.StatusNoContent},
+	} {
+		if rec := sendPolkaWebhook(t, cfg, tc.secret, tc.body); rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+
+	// Without secrets the legacy ApiKey header is checked instead.
+	cfg = newTestConfig(t)
+	cfg.polkaApiKey = "test-key"
+	req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(`{"event":"user.downgraded"}`))
+	req.Header.Set("Authorization", "ApiKey wrong-key")
+	rec := httptest.NewRecorder()
+	cfg.handlePolkaWebhook(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong ApiKey: got %d, want 401", rec.Code)
+	}
+}
+
+func TestPolkaWebhookIgnoresReplays(t *testing.T) {
+	secret := []byte("whsec_test")
+	cfg := newTestConfigWithDB(t)
+	cfg.polkaVerifier = webhook.NewVerifier(webhook.DefaultTolerance, secret)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	body := fmt.Sprintf(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":%q}}`, user.ID)
+
+	if rec := sendPolkaWebhook(t, cfg, secret, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("first delivery = %d %s, want 204", rec.Code, rec.Body)
+	}
+	if user, err := cfg.db.GetUserByID(context.Background(), user.ID); err != nil || !user.IsChirpyRed {
+		t.Fatalf("after first delivery is_chirpy_red = %v, %v, want true", user.IsChirpyRed, err)
+	}
+
+	// A replay is acknowledged but not applied again.
+	if _, err := cfg.db.ChangeChirpyRedStatus(context.Background(), database.ChangeChirpyRedStatusParams{ID: user.ID}); err != nil {
+		t.Fatalf("Error resetting Chirpy Red: %v", err)
+	}
+	if rec := sendPolkaWebhook(t, cfg, secret, body); rec.Code != http.StatusNoContent {
+		t.Fatalf("replay = %d %s, want 204", rec.Code, rec.Body)
+	}
+	if user, err := cfg.db.GetUserByID(context.Background(), user.ID); err != nil || user.IsChirpyRed {
+		t.Errorf("after replay is_chirpy_red = %v, %v, want false", user.IsChirpyRed, err)
+	}
+}
+
+func TestBlocksAndMutesHideRechirpsAndReplies(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	carol := createTestUser(t, cfg, "carol@example.com", roleUser)
+	dave := createTestUser(t, cfg, "dave@example.com", roleUser)
+	aliceToken := makeTestToken(t, cfg, alice)
+	rec := serve(t, cfg.handleBlockUser, "POST", "/api/users/"+bob.ID.String()+"/block", aliceToken, nil, "id", bob.ID.String())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("alice blocking bob = %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(t, cfg.handleMuteUser, "POST", "/api/users/"+carol.ID.String()+"/mute", aliceToken, nil, "id", carol.ID.String())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("alice muting carol = %d %s", rec.Code, rec.Body)
+	}
+
+	bobChirp := createTestChirp(t, cfg, bob, "from bob", uuid.NullUUID{})
+	carolChirp := createTestChirp(t, cfg, carol, "from carol", uuid.NullUUID{})
+	rechirp, err := cfg.db.CreateRechirp(context.Background(), database.CreateRechirpParams{UserID: dave.ID, RechirpOf: bobChirp.ID})
+	if err != nil {
+		t.Fatalf("Error rechirping: %v", err)
+	}
+	quote, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
+		UserID:  dave.ID,
+		Body:    "look at this",
+		QuoteOf: uuid.NullUUID{UUID: carolChirp.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Error quoting: %v", err)
+	}
+	daveChirp := createTestChirp(t, cfg, dave, "from dave", uuid.NullUUID{})
+
+	// Dave's own chirp shows; his rechirp of bob and quote of carol don't.
+	rec = serve(t, cfg.handleGetChirps, "GET", "/api/chirps", aliceToken, nil)
+	if got := chirpIDs(decodeResponse[[]chirpResponse](t, rec)); !slices.Equal(got, []uuid.UUID{daveChirp.ID}) {
+		t.Errorf("GET /api/chirps as alice = %v, want only %v (rechirp %v, quote %v hidden)", got, daveChirp.ID, rechirp.ID, quote.ID)
+	}
+
+	// Replies below a muted reply still show; only the muted one is left out.
+	carolReply := createTestChirp(t, cfg, carol, "reply from carol", uuid.NullUUID{UUID: daveChirp.ID, Valid: true})
+	daveReply := createTestChirp(t, cfg, dave, "reply to carol", uuid.NullUUID{UUID: carolReply.ID, Valid: true})
+	rec = serve(t, cfg.handleGetChirpThread, "GET", "/api/chirps/"+daveChirp.ID.String()+"/thread", aliceToken, nil, "id", daveChirp.ID.String())
+	var replies []uuid.UUID
+	for _, reply := range decodeResponse[chirpThread](t, rec).Replies {
+		replies = append(replies, reply.ID)
+	}
+	if !slices.Equal(replies, []uuid.UUID{daveReply.ID}) {
+		t.Errorf("dave's thread as alice has replies %v, want only %v", replies, daveReply.ID)
+	}
+
+	rec = serve(t, cfg.handleFollowUser, "POST", "/api/users/"+alice.ID.String()+"/follow", makeTestToken(t, cfg, bob), nil, "id", alice.ID.String())
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("bob following alice = %d, want 403", rec.Code)
+	}
+}
+
+func TestShadowBannedChirpsCantBeActedOn(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	bobChirp := createTestChirp(t, cfg, bob, "hello from bob", uuid.NullUUID{})
+	now := time.Now().UTC()
+	if _, err := cfg.db.SetAccountState(context.Background(), database.SetAccountStateParams{ID: bob.ID, ShadowBannedAt: &now}); err != nil {
+		t.Fatalf("Error shadow-banning bob: %v", err)
+	}
+	id := bobChirp.ID.String()
+
+	for _, viewer := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"alice", makeTestToken(t, cfg, alice), http.StatusNotFound},
+		{"bob", makeTestToken(t, cfg, bob), http.StatusCreated},
+	} {
+		for _, tc := range []struct {
+			name    string
+			handler http.HandlerFunc
+			target  string
+			body    any
+		}{
+			{"reply", cfg.handleCreateChirp, "/api/chirps", map[string]any{"body": "a reply", "in_reply_to": id}},
+			{"quote", cfg.handleCreateChirp, "/api/chirps", map[string]any{"body": "a quote", "quote_of": id}},
+			{"rechirp", cfg.handleRechirp, "/api/chirps/" + id + "/rechirp", nil},
+		} {
+			rec := serve(t, tc.handler, "POST", tc.target, viewer.token, tc.body, "id", id)
+			if rec.Code != viewer.want {
+				t.Errorf("%s as %s = %d %s, want %d", tc.name, viewer.name, rec.Code, rec.Body, viewer.want)
+			}
+		}
+	}
+
+	aliceToken := makeTestToken(t, cfg, alice)
+	rec := serve(t, cfg.handleLikeChirp, "POST", "/api/chirps/"+id+"/like", aliceToken, nil, "id", id)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("like as alice = %d, want 404", rec.Code)
+	}
+	rec = serve(t, cfg.handleReportChirp, "POST", "/api/chirps/"+id+"/report", aliceToken, map[string]string{"reason": "spam"}, "id", id)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("report as alice = %d, want 404", rec.Code)
+	}
+}
+
+func TestReplyCountsFollowDeletedAccounts(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	carol := createTestUser(t, cfg, "carol@example.com", roleUser)
+	root := createTestChirp(t, cfg, alice, "root", uuid.NullUUID{})
+	bobReply := createTestChirp(t, cfg, bob, "reply from bob", uuid.NullUUID{UUID: root.ID, Valid: true})
+	createTestChirp(t, cfg, carol, "reply from carol", uuid.NullUUID{UUID: bobReply.ID, Valid: true})
+	getChirp := func(id uuid.UUID) (database.Chirp, error) {
+		return cfg.db.GetChirpByID(context.Background(), id)
+	}
+	if chirp, err := getChirp(root.ID); err != nil || chirp.ReplyCount != 1 {
+		t.Fatalf("root reply_count = %d, %v, want 1", chirp.ReplyCount, err)
+	}
+
+	// Bob's reply has a reply of its own, so deleting it leaves a tombstone.
+	rec := serve(t, cfg.handleDeleteChirp, "DELETE", "/api/chirps/"+bobReply.ID.String(), makeTestToken(t, cfg, bob), nil, "id", bobReply.ID.String())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("deleting bob's reply = %d %s", rec.Code, rec.Body)
+	}
+	if chirp, err := getChirp(bobReply.ID); err != nil || chirp.DeletedAt == nil {
+		t.Fatalf("bob's reply = %+v, %v, want a tombstone", chirp, err)
+	}
+
+	// Deleting carol cascades to her reply, which takes the tombstone along.
+	if _, err := cfg.dbConn.Exec("DELETE FROM users WHERE id = $1", carol.ID); err != nil {
+		t.Fatalf("Error deleting carol: %v", err)
+	}
+	if _, err := getChirp(bobReply.ID); err != sql.ErrNoRows {
+		t.Errorf("tombstone after deleting carol: err = %v, want sql.ErrNoRows", err)
+	}
+	if chirp, err := getChirp(root.ID); err != nil || chirp.ReplyCount != 0 {
+		t.Errorf("root reply_count = %d, %v, want 0", chirp.ReplyCount, err)
+	}
+}
+
+func TestSearchChirps(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	fox := createTestChirp(t, cfg, alice, "The quick brown fox jumps", uuid.NullUUID{})
+	foxes := createTestChirp(t, cfg, bob, "Foxes are quick", uuid.NullUUID{})
+	createTestChirp(t, cfg, bob, "Lazy dogs sleeping", uuid.NullUUID{})
+	sorted := func(ids ...uuid.UUID) []uuid.UUID {
+		slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+		return ids
+	}
+	search := func(query string) []uuid.UUID {
+		t.Helper()
+		target := "/api/chirps/search?" + query
+		rec := serve(t, cfg.handleSearchChirps, "GET", target, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", target, rec.Code, rec.Body)
+		}
+		if strings.Contains(rec.Body.String(), "search_vector") {
+			t.Errorf("GET %s exposes search_vector: %s", target, rec.Body)
+		}
+		return sorted(chirpIDs(decodeResponse[chirpsPage](t, rec).Chirps)...)
+	}
+
+	if got, want := search("q=fox"), sorted(fox.ID, foxes.ID); !slices.Equal(got, want) {
+		t.Errorf("q=fox = %v, want %v", got, want)
+	}
+	if got, want := search(`q="quick+brown"`), sorted(fox.ID); !slices.Equal(got, want) {
+		t.Errorf(`q="quick brown" = %v, want %v`, got, want)
+	}
+	if got, want := search("q=fox&author_id="+bob.ID.String()), sorted(foxes.ID); !slices.Equal(got, want) {
+		t.Errorf("q=fox by bob = %v, want %v", got, want)
+	}
+	if got := search("q=cats"); len(got) != 0 {
+		t.Errorf("q=cats = %v, want nothing", got)
+	}
+	rec := serve(t, cfg.handleSearchChirps, "GET", "/api/chirps/search?q=%26%26", "", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("searching for punctuation only = %d, want 400", rec.Code)
+	}
+}
+
+func TestEditChirpKeepsHistory(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	chirp := createTestChirp(t, cfg, alice, "first", uuid.NullUUID{})
+	target := "/api/chirps/" + chirp.ID.String()
+	edit := func(user database.User, body string) int {
+		t.Helper()
+		rec := serve(t, cfg.handleUpdateChirp, "PUT", target, makeTestToken(t, cfg, user), map[string]string{"body": body}, "id", chirp.ID.String())
+		return rec.Code
+	}
+
+	for _, body := range []string{"second", "third", "third"} {
+		if got := edit(alice, body); got != http.StatusOK {
+			t.Fatalf("editing to %q = %d, want 200", body, got)
+		}
+	}
+	if got := edit(bob, "hijacked"); got != http.StatusForbidden {
+		t.Errorf("editing someone else's chirp = %d, want 403", got)
+	}
+
+	rec := serve(t, cfg.handleGetChirpByID, "GET", target, "", nil, "id", chirp.ID.String())
+	if got := decodeResponse[chirpResponse](t, rec); got.Body != "third" {
+		t.Errorf("chirp body = %q, want %q", got.Body, "third")
+	}
+	rec = serve(t, cfg.handleGetChirpHistory, "GET", target+"/history", "", nil, "id", chirp.ID.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s/history = %d %s", target, rec.Code, rec.Body)
+	}
+	var bodies []string
+	for _, revision := range decodeResponse[[]database.ChirpRevision](t, rec) {
+		bodies = append(bodies, revision.Body)
+	}
+	// Saving the same body again doesn't add a revision.
+	if want := []string{"second", "first"}; !slices.Equal(bodies, want) {
+		t.Errorf("history = %q, want %q", bodies, want)
+	}
+}
+
+func TestChirpThread(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	alice := createTestUser(t, cfg, "alice@example.com", roleUser)
+	bob := createTestUser(t, cfg, "bob@example.com", roleUser)
+	root := createTestChirp(t, cfg, alice, "root", uuid.NullUUID{})
+	reply := func(user database.User, parent uuid.UUID) chirpResponse {
+		t.Helper()
+		rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", makeTestToken(t, cfg, user), map[string]any{"body": "reply", "in_reply_to": parent})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("replying to %s = %d %s", parent, rec.Code, rec.Body)
+		}
+		return decodeResponse[chirpResponse](t, rec)
+	}
+	first := reply(bob, root.ID)
+	nested := reply(alice, first.ID)
+	second := reply(bob, root.ID)
+
+	getThread := func(id uuid.UUID, query string) chirpThread {
+		t.Helper()
+		target := "/api/chirps/" + id.String() + "/thread" + query
+		rec := serve(t, cfg.handleGetChirpThread, "GET", target, "", nil, "id", id.String())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", target, rec.Code, rec.Body)
+		}
+		return decodeResponse[chirpThread](t, rec)
+	}
+	type entry struct {
+		id    uuid.UUID
+		depth int32
+	}
+	entries := func(replies []threadReply) []entry {
+		var got []entry
+		for _, r := range replies {
+			got = append(got, entry{r.ID, r.Depth})
+		}
+		return got
+	}
+
+	thread := getThread(root.ID, "")
+	if thread.Chirp.ReplyCount != 2 {
+		t.Errorf("root reply_count = %d, want 2", thread.Chirp.ReplyCount)
+	}
+	want := []entry{{first.ID, 1}, {nested.ID, 2}, {second.ID, 1}}
+	if got := entries(thread.Replies); !slices.Equal(got, want) {
+		t.Errorf("root replies = %v, want %v", got, want)
+	}
+
+	var paged []entry
+	query := "?limit=1"
+	for range want {
+		page := getThread(root.ID, query)
+		paged = append(paged, entries(page.Replies)...)
+		query = "?limit=1&cursor=" + page.NextCursor
+	}
+	if !slices.Equal(paged, want) {
+		t.Errorf("paged replies = %v, want %v", paged, want)
+	}
+
+	thread = getThread(nested.ID, "")
+	var ancestors []uuid.UUID
+	for _, a := range thread.Ancestors {
+		ancestors = append(ancestors, a.ID)
+	}
+	if want := []uuid.UUID{root.ID, first.ID}; !slices.Equal(ancestors, want) {
+		t.Errorf("ancestors of the nested reply = %v, want %v", ancestors, want)
+	}
+	if len(thread.Replies) != 0 {
+		t.Errorf("nested reply has replies %v, want none", entries(thread.Replies))
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	login := loginTestUser(t, cfg, user, "test")
+	refresh := func(token string) (loginResponse, int) {
+		t.Helper()
+		rec := serve(t, cfg.handleRefreshToken, "POST", "/api/refresh", token, nil)
+		if rec.Code != http.StatusOK {
+			return loginResponse{}, rec.Code
+		}
+		return decodeResponse[loginResponse](t, rec), rec.Code
+	}
+
+	second, code := refresh(login.RefreshToken)
+	if code != http.StatusOK || second.Token == "" || second.RefreshToken == "" || second.RefreshToken == login.RefreshToken {
+		t.Fatalf("refresh = %d %+v, want 200 with a new refresh token", code, second)
+	}
+	third, code := refresh(second.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refreshing the rotated token = %d, want 200", code)
+	}
+	// The first token was rotated out, so presenting it again means it was
+	// copied: it fails and takes the rest of its family down with it.
+	if _, code := refresh(login.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("reusing a rotated-out token = %d, want 401", code)
+	}
+	if _, code := refresh(third.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refreshing after reuse was detected = %d, want 401", code)
+	}
+
+	// Another login is a family of its own and isn't affected.
+	other, code := refresh(loginTestUser(t, cfg, user, "test").RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("refreshing another login = %d, want 200", code)
+	}
+	if rec := serve(t, cfg.handleRevokeRefreshToken, "POST", "/api/revoke", other.RefreshToken, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("revoking = %d %s", rec.Code, rec.Body)
+	}
+	if _, code := refresh(other.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refreshing a revoked token = %d, want 401", code)
+	}
+	if _, code := refresh("not-a-token"); code != http.StatusUnauthorized {
+		t.Errorf("refreshing an unknown token = %d, want 401", code)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxTokenNameLength   = 100
+	maxTokenLifetimeDays = 365
+)
+
+func (cfg *apiConfig) handleCreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	var body struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays *int     `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len(body.Name) > maxTokenNameLength {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid name")
+		return
+	}
+	if len(body.Scopes) == 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range body.Scopes {
+		if !slices.Contains(allScopes, scope) {
+			sendErrorResponse(w, http.StatusBadRequest, "Unknown scope "+scope)
+			return
+		}
+	}
+	slices.Sort(body.Scopes)
+	body.Scopes = slices.Compact(body.Scopes)
+	var expiresAt *time.Time
+	if body.ExpiresInDays != nil {
+		days := *body.ExpiresInDays
+		if days < 1 || days > maxTokenLifetimeDays {
+			sendErrorResponse(w, http.StatusBadRequest, "Invalid expires_in_days")
+			return
+		}
+		t := time.Now().AddDate(0, 0, days)
+		expiresAt = &t
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	token := patPrefix + secret
+	pat, err := cfg.db.CreatePersonalAccessToken(context.Background(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      body.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    body.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// The token itself is only ever shown here.
+	sendJSONResponse(w, http.StatusCreated, struct {
+		database.PersonalAccessToken
+		Token string `json:"token"`
+	}{
+		PersonalAccessToken: pat,
+		Token:               token,
+	})
+}
+
+func (cfg *apiConfig) handleGetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	tokens, err := cfg.db.GetPersonalAccessTokens(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tokens == nil {
+		tokens = []database.PersonalAccessToken{}
+	}
+	sendJSONResponse(w, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) handleRevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	revoked, err := cfg.db.RevokePersonalAccessToken(context.Background(), database.RevokePersonalAccessTokenParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if revoked == 0 {
+		sendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
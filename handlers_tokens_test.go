@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	token := makeTestToken(t, cfg, user)
+
+	for _, body := range []map[string]any{
+		{"name": "bot", "scopes": []string{"chirps:everything"}},
+		{"name": "bot", "scopes": []string{}},
+		{"name": " ", "scopes": []string{scopeChirpsWrite}},
+		{"name": "bot", "scopes": []string{scopeChirpsWrite}, "expires_in_days": 0},
+	} {
+		if rec := serve(t, cfg.handleCreatePersonalAccessToken, "POST", "/api/tokens", token, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("creating a token with %v = %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec := serve(t, cfg.handleCreatePersonalAccessToken, "POST", "/api/tokens", token, map[string]any{
+		"name":            "bot",
+		"scopes":          []string{scopeChirpsWrite, scopeChirpsWrite},
+		"expires_in_days": 30,
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating a token = %d %s", rec.Code, rec.Body)
+	}
+	created := decodeResponse[struct {
+		database.PersonalAccessToken
+		Token string `json:"token"`
+	}](t, rec)
+	if !strings.HasPrefix(created.Token, patPrefix) || len(created.Scopes) != 1 || created.ExpiresAt == nil {
+		t.Errorf("created token = %+v, want a %s token with one scope and an expiry", created, patPrefix)
+	}
+
+	rec = serve(t, cfg.handleGetPersonalAccessTokens, "GET", "/api/tokens", token, nil)
+	if strings.Contains(rec.Body.String(), created.Token) {
+		t.Errorf("listing tokens shows the token itself: %s", rec.Body)
+	}
+	if listed := decodeResponse[[]database.PersonalAccessToken](t, rec); len(listed) != 1 || listed[0].ID != created.ID {
+		t.Errorf("listed tokens = %+v, want the created one", listed)
+	}
+
+	if rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", created.Token, map[string]any{"body": "from a bot"}); rec.Code != http.StatusCreated {
+		t.Errorf("posting with the token = %d %s, want 201", rec.Code, rec.Body)
+	}
+	// Managing tokens needs a real login.
+	if rec := serve(t, cfg.handleGetPersonalAccessTokens, "GET", "/api/tokens", created.Token, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("listing tokens with a token = %d, want 403", rec.Code)
+	}
+
+	target := "/api/tokens/" + created.ID.String()
+	if rec := serve(t, cfg.handleRevokePersonalAccessToken, "DELETE", target, token, nil, "id", created.ID.String()); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE %s = %d %s", target, rec.Code, rec.Body)
+	}
+	if rec := serve(t, cfg.handleCreateChirp, "POST", "/api/chirps", created.Token, map[string]any{"body": "from a bot"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("posting with a revoked token = %d, want 401", rec.Code)
+	}
+	if rec := serve(t, cfg.handleRevokePersonalAccessToken, "DELETE", target, token, nil, "id", created.ID.String()); rec.Code != http.StatusNotFound {
+		t.Errorf("revoking twice = %d, want 404", rec.Code)
+	}
+}
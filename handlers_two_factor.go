@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer        = "Chirpy"
+	recoveryCodeCount = 10
+)
+
+// verifySecondFactor checks a TOTP code or an unused recovery code for user
+// and uses it up, so neither can be presented twice. Every recovery code
+// costs an Argon2id hash to check, so callers put it behind the login
+// throttle.
+func verifySecondFactor(ctx context.Context, q *database.Queries, user database.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	isTOTP := len(code) == 6 && strings.IndexFunc(code, func(r rune) bool { return r < '0' || r > '9' }) == -1
+	if isTOTP {
+		step, err := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now(), user.TotpLastStep)
+		if errors.Is(err, auth.ErrInvalidTOTPCode) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		used, err := q.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			ID:           user.ID,
+			TotpLastStep: step,
+		})
+		return used > 0, err
+	}
+
+	recoveryCodes, err := q.GetUnusedRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+	code = auth.NormalizeRecoveryCode(code)
+	if !auth.IsRecoveryCode(code) {
+		return false, nil
+	}
+	for _, recoveryCode := range recoveryCodes {
+		match, err := auth.VerifyPassword(code, recoveryCode.CodeHash)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			used, err := q.UseRecoveryCode(ctx, recoveryCode.ID)
+			return used > 0, err
+		}
+	}
+	return false, nil
+}
+
+func (cfg *apiConfig) handleEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if user.TotpEnabledAt != nil {
+		sendErrorResponse(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = cfg.db.SetTOTPSecret(context.Background(), database.SetTOTPSecretParams{
+		ID:         userID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) handleConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if user.TotpEnabledAt != nil {
+		sendErrorResponse(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		sendErrorResponse(w, http.StatusBadRequest, "Two-factor enrollment has not been started")
+		return
+	}
+	step, err := auth.ValidateTOTP(user.TotpSecret.String, strings.TrimSpace(body.Code), time.Now(), user.TotpLastStep)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+	recoveryCodes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.EnableTOTP(context.Background(), database.EnableTOTPParams{
+		ID:           userID,
+		TotpLastStep: step,
+	})
+	if err == nil {
+		err = qtx.DeleteRecoveryCodes(context.Background(), userID)
+	}
+	for _, code := range recoveryCodes {
+		if err != nil {
+			break
+		}
+		var hash string
+		hash, err = auth.HashPassword(code)
+		if err == nil {
+			err = qtx.CreateRecoveryCode(context.Background(), database.CreateRecoveryCodeParams{
+				UserID:   userID,
+				CodeHash: hash,
+			})
+		}
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (cfg *apiConfig) handleDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authorize(w, r, scopeLoginOnly)
+	if !ok {
+		return
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.GetUserByID(context.Background(), userID)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if user.TotpEnabledAt == nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+	if !cfg.checkLoginThrottle(w, r, user.Email) {
+		return
+	}
+	verified, err := verifySecondFactor(context.Background(), qtx, user, body.Code)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !verified {
+		cfg.recordLoginFailure(r, user.Email, uuid.NullUUID{UUID: user.ID, Valid: true})
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid code")
+		return
+	}
+	err = qtx.DisableTOTP(context.Background(), userID)
+	if err == nil {
+		err = qtx.DeleteRecoveryCodes(context.Background(), userID)
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(204)
+	w.Write([]byte{})
+}
+
+func (cfg *apiConfig) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, err := cfg.jwtKeys.ValidateChallengeJWT(body.ChallengeToken)
+	if err != nil {
+		sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired challenge token")
+		return
+	}
+	user, err := cfg.db.GetUserByID(context.Background(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired challenge token")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if user.TotpEnabledAt != nil {
+		if !cfg.checkLoginThrottle(w, r, user.Email) {
+			return
+		}
+		verified, err := verifySecondFactor(context.Background(), cfg.db, user, body.Code)
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !verified {
+			cfg.recordLoginFailure(r, user.Email, uuid.NullUUID{UUID: user.ID, Valid: true})
+			sendErrorResponse(w, http.StatusUnauthorized, "Invalid code")
+			return
+		}
+	}
+	if !checkNotSuspended(w, user) {
+		return
+	}
+	cfg.recordLoginSuccess(r, user.Email)
+	cfg.sendLoginResponse(w, r, user)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+func TestDisableTOTPIsThrottled(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Error generating secret: %v", err)
+	}
+	codes, err := auth.GenerateRecoveryCodes(1)
+	if err != nil {
+		t.Fatalf("Error generating recovery codes: %v", err)
+	}
+	hash, err := auth.HashPassword(codes[0])
+	if err == nil {
+		err = cfg.db.SetTOTPSecret(context.Background(), database.SetTOTPSecretParams{ID: user.ID, TotpSecret: sql.NullString{String: secret, Valid: true}})
+	}
+	if err == nil {
+		err = cfg.db.EnableTOTP(context.Background(), database.EnableTOTPParams{ID: user.ID})
+	}
+	if err == nil {
+		err = cfg.db.CreateRecoveryCode(context.Background(), database.CreateRecoveryCodeParams{UserID: user.ID, CodeHash: hash})
+	}
+	if err != nil {
+		t.Fatalf("Error enabling two-factor authentication: %v", err)
+	}
+	token := makeTestToken(t, cfg, user)
+
+	for _, code := range []string{"aaaaa-aaaaa", "not a code", "bbbbb-bbbbb"} {
+		rec := serve(t, cfg.handleDisableTOTP, "POST", "/api/2fa/disable", token, map[string]string{"code": code})
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("disabling with %q = %d, want 400", code, rec.Code)
+		}
+	}
+	rec := serve(t, cfg.handleDisableTOTP, "POST", "/api/2fa/disable", token, map[string]string{"code": codes[0]})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("disabling after three wrong codes = %d, want 429 with Retry-After", rec.Code)
+	}
+	unused, err := cfg.db.GetUnusedRecoveryCodes(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Error listing recovery codes: %v", err)
+	}
+	if len(unused) != 1 {
+		t.Errorf("a throttled attempt used up the recovery code")
+	}
+}
@@ -1,14 +1,121 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/mail"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
 )
 
-var profaneWords = []string{"kerfuffle", "sharbert", "fornax"}
+// pageCursor is the position of the last item of a page. It is handed to
+// clients as an opaque string and only ever decoded by the server.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Rank      float32   `json:"r,omitempty"`
+}
+
+type pageParams struct {
+	Limit  int32
+	Cursor *pageCursor
+	// Paged is set when the client sent a limit or a cursor, i.e. it knows
+	// about pagination.
+	Paged bool
+}
+
+func (p pageParams) cursorCreatedAt() sql.NullTime {
+	if p.Cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true}
+}
+
+func (p pageParams) cursorID() uuid.NullUUID {
+	if p.Cursor == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+func (p pageParams) cursorRank() sql.NullFloat64 {
+	if p.Cursor == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(p.Cursor.Rank), Valid: true}
+}
+
+// queryLimit is the number of rows to ask the database for. One extra row
+// tells us whether there is a next page without a separate count query.
+func (p pageParams) queryLimit() int32 {
+	return p.Limit + 1
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+func getPageParams(r *http.Request) (pageParams, error) {
+	page := pageParams{Limit: defaultPageLimit}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return page, errors.New("invalid limit")
+		}
+		page.Limit = int32(min(n, maxPageLimit))
+		page.Paged = true
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = c
+		page.Paged = true
+	}
+	return page, nil
+}
+
+// paginate trims the extra row fetched by queryLimit and returns the cursor
+// of the next page, or an empty string if this is the last one.
+func paginate[T any](items []T, page pageParams, cursorOf func(T) pageCursor) ([]T, string) {
+	if items == nil {
+		items = []T{}
+	}
+	if int32(len(items)) <= page.Limit {
+		return items, ""
+	}
+	items = items[:page.Limit]
+	return items, encodeCursor(cursorOf(items[len(items)-1]))
+}
 
 func getBearerToken(r *http.Request) (string, error) {
 	token := r.Header.Get("Authorization")
@@ -26,6 +133,22 @@ func getApiKey(r *http.Request) (string, error) {
 	return "", errors.New("invalid apikey")
 }
 
+// validEmail reports whether email is a bare address such as
+// "walt@example.com", without a display name or angle brackets.
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// getClientIP returns the address of the peer that sent the request.
+func getClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func sendJSONResponse(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
@@ -43,18 +166,162 @@ func sendErrorResponse(w http.ResponseWriter, status int, message string) {
 	w.Write(fmt.Appendf(make([]byte, 0, len(message)+13), "{\"error\": \"%s\"}", message))
 }
 
-func sanitizeChirpBody(body string) string {
-	oldWords := strings.Split(body, " ")
-	newWords := make([]string, len(oldWords))
-	for i, word := range oldWords {
-		for _, profaneWord := range profaneWords {
-			if strings.EqualFold(strings.ToLower(word), strings.ToLower(profaneWord)) {
-				newWords[i] = "****"
-				break
-			} else {
-				newWords[i] = word
+// sendUnauthorized answers 401 with a WWW-Authenticate challenge (RFC 6750)
+// explaining why the access token was rejected. A nil err means the request
+// carried no token at all.
+func sendUnauthorized(w http.ResponseWriter, err error) {
+	challenge := `Bearer realm="chirpy"`
+	if err != nil {
+		challenge += fmt.Sprintf(`, error="invalid_token", error_description=%q`, tokenErrorDescription(err))
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	sendErrorResponse(w, http.StatusUnauthorized, "Unauthorized")
+}
+
+func tokenErrorDescription(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		return "The access token expired"
+	case errors.Is(err, auth.ErrTokenNotYetValid):
+		return "The access token is not valid yet"
+	case errors.Is(err, auth.ErrInvalidIssuer):
+		return "The access token was issued by someone else"
+	case errors.Is(err, auth.ErrInvalidAudience):
+		return "The access token is not meant for this service"
+	case errors.Is(err, auth.ErrInvalidSignature):
+		return "The access token signature is invalid"
+	case errors.Is(err, auth.ErrUnknownKey):
+		return "The access token was signed with an unknown key"
+	case errors.Is(err, auth.ErrAlgorithmNotAllowed):
+		return "The access token signing algorithm is not allowed"
+	case errors.Is(err, auth.ErrWrongPurpose):
+		return "The token is not an access token"
+	case errors.Is(err, errInvalidPAT):
+		return "The personal access token is invalid, expired or revoked"
+	case errors.Is(err, errAccountClosed):
+		return "The account is suspended or no longer exists"
+	default:
+		return "The access token is malformed"
+	}
+}
+
+// validateChirpBody checks a chirp body submitted by a user. It returns the
+// version that should be stored and the terms to flag the chirp for.
+func (cfg *apiConfig) validateChirpBody(body string) (string, []string, error) {
+	if len(body) > 140 {
+		return "", nil, errors.New("Chirp is too long")
+	}
+	res := cfg.moderator.Check(body)
+	if res.Rejected {
+		return "", nil, errors.New("Chirp contains a banned term")
+	}
+	return res.Body, res.Flagged, nil
+}
+
+// buildSearchQuery turns user input into a to_tsquery expression. Words are
+// ANDed together, "quoted phrases" must match in order and a trailing * makes
+// a word match as a prefix. Anything other than letters and digits separates
+// words, so the result never contains tsquery syntax the user did not ask for.
+func buildSearchQuery(q string) string {
+	var terms []string
+	for i, part := range strings.Split(q, `"`) {
+		inPhrase := i%2 == 1
+		var words []string
+		for _, field := range strings.Fields(part) {
+			prefix := strings.HasSuffix(field, "*")
+			subwords := strings.FieldsFunc(field, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if len(subwords) == 0 {
+				continue
+			}
+			if prefix {
+				subwords[len(subwords)-1] += ":*"
+			}
+			words = append(words, subwords...)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		if inPhrase {
+			terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+		} else {
+			terms = append(terms, words...)
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+const (
+	maxHashtagLength     = 100
+	minHandleLength      = 3
+	maxHandleLength      = 30
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+func isHashtagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isHandleRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func validHandle(handle string) bool {
+	if len(handle) < minHandleLength || len(handle) > maxHandleLength {
+		return false
+	}
+	for _, r := range handle {
+		if !isHandleRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeHashtag lower-cases a tag and strips a leading '#', so "#Go" and
+// "go" name the same hashtag.
+func normalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// extractHashtags returns the distinct normalized #tags in a chirp body, in
+// the order they first appear.
+func extractHashtags(body string) []string {
+	return extractPrefixed(body, '#', isHashtagRune, maxHashtagLength)
+}
+
+// extractMentions returns the distinct lower-cased @handles in a chirp body,
+// in the order they first appear.
+func extractMentions(body string) []string {
+	return extractPrefixed(body, '@', isHandleRune, maxHandleLength)
+}
+
+// extractPrefixed finds words that start with sigil and continue with runes
+// accepted by isTokenRune. A sigil only starts a word at the beginning of the
+// body or after a rune that cannot be part of one, so "a@b.com" is not a
+// mention.
+func extractPrefixed(body string, sigil rune, isTokenRune func(rune) bool, maxLength int) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != sigil || (i > 0 && isTokenRune(runes[i-1])) {
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && isTokenRune(runes[j]) {
+			j++
+		}
+		if j > i+1 && j-i-1 <= maxLength {
+			token := strings.ToLower(string(runes[i+1 : j]))
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
 			}
 		}
+		i = j - 1
 	}
-	return strings.Join(newWords, " ")
+	return tokens
 }
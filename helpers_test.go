@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+func TestGetPageParams(t *testing.T) {
+	cursor := pageCursor{CreatedAt: time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC), ID: uuid.New()}
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int32
+		wantPaged bool
+		wantErr   bool
+	}{
+		{"defaults", "", defaultPageLimit, false, false},
+		{"limit", "limit=5", 5, true, false},
+		{"limit capped", "limit=500", maxPageLimit, true, false},
+		{"cursor only", "cursor=" + encodeCursor(cursor), defaultPageLimit, true, false},
+		{"zero limit", "limit=0", 0, false, true},
+		{"bad limit", "limit=ten", 0, false, true},
+		{"bad cursor", "cursor=not-a-cursor", 0, false, true},
+		{"cursor without id", "cursor=" + encodeCursor(pageCursor{CreatedAt: cursor.CreatedAt}), 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := getPageParams(httptest.NewRequest("GET", "/api/chirps?"+tt.query, nil))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("getPageParams() = %+v, want error", page)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getPageParams() error = %v", err)
+			}
+			if page.Limit != tt.wantLimit || page.Paged != tt.wantPaged {
+				t.Errorf("getPageParams() = limit %d, paged %v, want limit %d, paged %v", page.Limit, page.Paged, tt.wantLimit, tt.wantPaged)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := pageCursor{CreatedAt: time.Date(2025, 10, 18, 12, 0, 0, 123456000, time.UTC), ID: uuid.New(), Rank: 0.25}
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID || got.Rank != want.Rank {
+		t.Errorf("decodeCursor() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	cursorOf := func(n int) pageCursor {
+		return pageCursor{ID: uuid.UUID{byte(n)}}
+	}
+	page := pageParams{Limit: 2}
+
+	// queryLimit fetches one row more than the page holds.
+	items, next := paginate([]int{1, 2, 3}, page, cursorOf)
+	if len(items) != 2 || next == "" {
+		t.Fatalf("paginate() = %v, %q, want 2 items and a cursor", items, next)
+	}
+	if c, err := decodeCursor(next); err != nil || c.ID != cursorOf(2).ID {
+		t.Errorf("next cursor = %+v, %v, want the last item's", c, err)
+	}
+
+	items, next = paginate([]int{1, 2}, page, cursorOf)
+	if len(items) != 2 || next != "" {
+		t.Errorf("paginate() on the last page = %v, %q, want 2 items and no cursor", items, next)
+	}
+
+	items, next = paginate[int](nil, page, cursorOf)
+	if items == nil || len(items) != 0 || next != "" {
+		t.Errorf("paginate(nil) = %#v, %q, want an empty slice and no cursor", items, next)
+	}
+}
+
+func TestBuildSearchQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"fox", "fox"},
+		{"quick fox", "quick & fox"},
+		{`"quick brown" fox`, "(quick <-> brown) & fox"},
+		{"chirp*", "chirp:*"},
+		{"fox & !dog | (cat)", "fox & dog & cat"},
+		{"it's", "it & s"},
+		{`"unclosed phrase`, "(unclosed <-> phrase)"},
+		{"café", "café"},
+	}
+	for _, tt := range tests {
+		if got := buildSearchQuery(tt.q); got != tt.want {
+			t.Errorf("buildSearchQuery(%q) = %q, want %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"no tags here", nil},
+		{"Loving #Go and #golang! #go again", []string{"go", "golang"}},
+		{"#café_2025 rocks", []string{"café_2025"}},
+		{"issue#42 is not a tag", nil},
+		{"##double", []string{"double"}},
+		{"# alone", nil},
+		{"#" + strings.Repeat("a", maxHashtagLength+1), nil},
+	}
+	for _, tt := range tests {
+		if got := extractHashtags(tt.body); !slices.Equal(got, tt.want) {
+			t.Errorf("extractHashtags(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"no mentions", nil},
+		{"hi @Bob and @bob, meet @carol_1", []string{"bob", "carol_1"}},
+		{"mail me at a@b.com", nil},
+		{"(@dave)", []string{"dave"}},
+		{"@" + strings.Repeat("a", maxHandleLength+1), nil},
+	}
+	for _, tt := range tests {
+		if got := extractMentions(tt.body); !slices.Equal(got, tt.want) {
+			t.Errorf("extractMentions(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	tests := []struct {
+		handle string
+		want   bool
+	}{
+		{"bob", true},
+		{"Alice_1", true},
+		{"ab", false},
+		{strings.Repeat("a", maxHandleLength), true},
+		{strings.Repeat("a", maxHandleLength+1), false},
+		{"has space", false},
+		{"café", false},
+		{"@bob", false},
+	}
+	for _, tt := range tests {
+		if got := validHandle(tt.handle); got != tt.want {
+			t.Errorf("validHandle(%q) = %v, want %v", tt.handle, got, tt.want)
+		}
+	}
+}
+
+func TestValidateChirpBody(t *testing.T) {
+	cfg := newTestConfig(t)
+	moderator, err := moderation.NewModerator(context.Background(), moderation.StaticSource{
+		{Word: "fornax", Action: moderation.ActionMask},
+		{Word: "sharbert", Action: moderation.ActionFlag},
+		{Word: "kerfuffle", Action: moderation.ActionReject},
+	})
+	if err != nil {
+		t.Fatalf("Error creating moderator: %v", err)
+	}
+	cfg.moderator = moderator
+	tests := []struct {
+		body        string
+		wantBody    string
+		wantFlagged []string
+		wantErr     bool
+	}{
+		{"hello", "hello", nil, false},
+		{"what a F0RNAX!", "what a ****!", nil, false},
+		{"hello sharbert", "hello sharbert", []string{"sharbert"}, false},
+		{"such a kerfuffle", "", nil, true},
+		{strings.Repeat("a", 141), "", nil, true},
+	}
+	for _, tt := range tests {
+		body, flagged, err := cfg.validateChirpBody(tt.body)
+		if (err != nil) != tt.wantErr || body != tt.wantBody || !slices.Equal(flagged, tt.wantFlagged) {
+			t.Errorf("validateChirpBody(%q) = %q, %q, %v, want %q, %q, error %v", tt.body, body, flagged, err, tt.wantBody, tt.wantFlagged, tt.wantErr)
+		}
+	}
+}
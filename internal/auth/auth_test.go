@@ -110,3 +110,24 @@ func TestJWTInvalidToken(t *testing.T) {
 		t.Errorf("Token user ID should be empty")
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	hash := auth.HashToken(token)
+	if hash == token || len(hash) != 64 {
+		t.Errorf("Unexpected token hash: %q", hash)
+	}
+	if auth.HashToken(token) != hash {
+		t.Errorf("Expected hashing to be deterministic")
+	}
+	other, err := auth.MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+	if auth.HashToken(other) == hash {
+		t.Errorf("Expected different tokens to hash differently")
+	}
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const minRSAKeyBits = 2048
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a single JWT key. Keys parsed from a public key can only verify
+// tokens; everything else can also sign them.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+// NewHMACKey wraps a shared secret as an HS256 key. Tokens signed with a key
+// whose ID is empty carry no kid header, which is what tokens issued before
+// the key ring existed look like.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// ParseKeyPEM parses an RSA or Ed25519 key. Private keys yield RS256 and
+// EdDSA signing keys; public keys yield verification-only keys.
+func ParseKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM data found", id)
+	}
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	key := &Key{ID: id}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", id, parsed)
+	}
+	if pub, ok := key.verifyKey.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSAKeyBits {
+		return nil, fmt.Errorf("key %q: RSA keys must be at least %d bits", id, minRSAKeyBits)
+	}
+	return key, nil
+}
+
+// LoadKeys parses every *.pem file in dir, using the file name without its
+// extension as the key ID.
+func LoadKeys(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKeyPEM(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeyRing signs tokens with one key and verifies them with any key it holds,
+// so old keys can keep verifying outstanding tokens after a rotation.
+type KeyRing struct {
+	signing *Key
+	keys    map[string]*Key
+	opts    ValidatorOptions
+	parser  *jwt.Parser
+}
+
+func NewKeyRing(opts ValidatorOptions, signingKeyID string, keys ...*Key) (*KeyRing, error) {
+	kr := &KeyRing{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, ok := kr.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+	}
+	if opts.Issuer == "" {
+		opts.Issuer = DefaultIssuer
+	}
+	if opts.Algorithms == nil {
+		for _, key := range keys {
+			if !opts.allows(key.Method.Alg()) {
+				opts.Algorithms = append(opts.Algorithms, key.Method.Alg())
+			}
+		}
+	}
+	kr.opts = opts
+	kr.parser = jwt.NewParser(opts.parserOptions()...)
+	signing, ok := kr.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q: %w", signingKeyID, ErrUnknownKey)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKeyID)
+	}
+	if !opts.allows(signing.Method.Alg()) {
+		return nil, fmt.Errorf("signing key %q: %w", signingKeyID, ErrAlgorithmNotAllowed)
+	}
+	kr.signing = signing
+	return kr, nil
+}
+
+// tokenClaims are the claims of every token a KeyRing issues. Purpose is
+// empty for access tokens; other tokens, such as 2FA challenges, set it so
+// they can't be used in place of an access token.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Purpose string `json:"purpose,omitempty"`
+	Role    string `json:"role,omitempty"`
+}
+
+// AccessClaims is what an access token says about the user it was issued to.
+type AccessClaims struct {
+	UserID uuid.UUID
+	// Role is empty in tokens issued without one.
+	Role string
+}
+
+func (kr *KeyRing) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return kr.makeJWT(AccessClaims{UserID: userID}, expiresIn, "")
+}
+
+// MakeAccessJWT issues an access token that also carries the user's role, so
+// authorization checks don't need to look it up.
+func (kr *KeyRing) MakeAccessJWT(access AccessClaims, expiresIn time.Duration) (string, error) {
+	return kr.makeJWT(access, expiresIn, "")
+}
+
+// MakeChallengeJWT issues a token that only proves the password step of a
+// two-factor login succeeded.
+func (kr *KeyRing) MakeChallengeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return kr.makeJWT(AccessClaims{UserID: userID}, expiresIn, PurposeTwoFactorChallenge)
+}
+
+func (kr *KeyRing) makeJWT(access AccessClaims, expiresIn time.Duration, purpose string) (string, error) {
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    kr.opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   access.UserID.String(),
+		},
+		Purpose: purpose,
+		Role:    access.Role,
+	}
+	if kr.opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{kr.opts.Audience}
+	}
+	token := jwt.NewWithClaims(kr.signing.Method, claims)
+	if kr.signing.ID != "" {
+		token.Header["kid"] = kr.signing.ID
+	}
+	return token.SignedString(kr.signing.signKey)
+}
+
+// ValidateJWT checks an access token against the ring's keys and
+// ValidatorOptions and returns the user it was issued to. Errors wrap one of
+// the Err* values of this package.
+func (kr *KeyRing) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	access, err := kr.validateJWT(tokenString, "")
+	return access.UserID, err
+}
+
+// ValidateAccessJWT is ValidateJWT for callers that also need the role.
+func (kr *KeyRing) ValidateAccessJWT(tokenString string) (AccessClaims, error) {
+	return kr.validateJWT(tokenString, "")
+}
+
+func (kr *KeyRing) ValidateChallengeJWT(tokenString string) (uuid.UUID, error) {
+	access, err := kr.validateJWT(tokenString, PurposeTwoFactorChallenge)
+	return access.UserID, err
+}
+
+func (kr *KeyRing) validateJWT(tokenString, purpose string) (AccessClaims, error) {
+	claims := &tokenClaims{}
+	if _, err := kr.parser.ParseWithClaims(tokenString, claims, kr.keyFunc); err != nil {
+		return AccessClaims{}, classifyError(err)
+	}
+	if claims.Purpose != purpose {
+		return AccessClaims{}, ErrWrongPurpose
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return AccessClaims{}, fmt.Errorf("%w: invalid subject: %w", ErrTokenMalformed, err)
+	}
+	return AccessClaims{UserID: id, Role: claims.Role}, nil
+}
+
+// keyFunc picks the key named by the token's kid and refuses tokens whose
+// alg is not allowed or doesn't match that key, so a public key can never be
+// used as an HMAC secret.
+func (kr *KeyRing) keyFunc(token *jwt.Token) (any, error) {
+	alg := token.Method.Alg()
+	if !kr.opts.allows(alg) {
+		return nil, fmt.Errorf("%w: %q", ErrAlgorithmNotAllowed, alg)
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if alg != key.Method.Alg() {
+		return nil, fmt.Errorf("%w: %q for key %q", ErrAlgorithmNotAllowed, alg, kid)
+	}
+	return key.verifyKey, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every asymmetric key in the ring. HMAC
+// keys are shared secrets and are never published.
+func (kr *KeyRing) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range kr.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	slices.SortFunc(set.Keys, func(a, b JWK) int { return strings.Compare(a.Kid, b.Kid) })
+	return set
+}
@@ -0,0 +1,213 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func rsaKeyPEM(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %v", err)
+	}
+	return encodeKeyPEM(t, key, &key.PublicKey)
+}
+
+func ed25519KeyPEM(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating Ed25519 key: %v", err)
+	}
+	return encodeKeyPEM(t, key, pub)
+}
+
+func encodeKeyPEM(t *testing.T, private, public any) ([]byte, []byte) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("Error marshalling private key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("Error marshalling public key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+func mustParseKey(t *testing.T, id string, data []byte) *auth.Key {
+	t.Helper()
+	key, err := auth.ParseKeyPEM(id, data)
+	if err != nil {
+		t.Fatalf("Error parsing key %q: %v", id, err)
+	}
+	return key
+}
+
+func TestKeyRingSignsAndVerifies(t *testing.T) {
+	rsaPEM, _ := rsaKeyPEM(t)
+	edPEM, _ := ed25519KeyPEM(t)
+	for _, tc := range []struct {
+		name string
+		key  *auth.Key
+		alg  string
+	}{
+		{"RS256", mustParseKey(t, "rsa-1", rsaPEM), "RS256"},
+		{"EdDSA", mustParseKey(t, "ed-1", edPEM), "EdDSA"},
+		{"HS256", auth.NewHMACKey("", []byte("my-super-secret-key")), "HS256"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ring, err := auth.NewKeyRing(auth.ValidatorOptions{}, tc.key.ID, tc.key)
+			if err != nil {
+				t.Fatalf("Error creating key ring: %v", err)
+			}
+			userID := uuid.New()
+			tokenString, err := ring.MakeJWT(userID, time.Hour)
+			if err != nil {
+				t.Fatalf("Error generating JWT token: %v", err)
+			}
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatalf("Error parsing JWT token: %v", err)
+			}
+			if token.Method.Alg() != tc.alg {
+				t.Errorf("Expected alg %s, got %s", tc.alg, token.Method.Alg())
+			}
+			if kid, _ := token.Header["kid"].(string); kid != tc.key.ID {
+				t.Errorf("Expected kid %q, got %q", tc.key.ID, kid)
+			}
+			tokenUserID, err := ring.ValidateJWT(tokenString)
+			if err != nil {
+				t.Fatalf("Error validating JWT token: %v", err)
+			}
+			if tokenUserID != userID {
+				t.Errorf("Token user ID does not match expected user ID")
+			}
+		})
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	oldPrivate, oldPublic := rsaKeyPEM(t)
+	newPrivate, _ := ed25519KeyPEM(t)
+	oldRing, err := auth.NewKeyRing(auth.ValidatorOptions{}, "old", mustParseKey(t, "old", oldPrivate))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	oldToken, err := oldRing.MakeJWT(uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+
+	// After rotation only the public half of the old key is kept around.
+	ring, err := auth.NewKeyRing(auth.ValidatorOptions{}, "new", mustParseKey(t, "new", newPrivate), mustParseKey(t, "old", oldPublic))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	if _, err := ring.ValidateJWT(oldToken); err != nil {
+		t.Errorf("Expected token signed with the old key to validate: %v", err)
+	}
+	if _, err := auth.NewKeyRing(auth.ValidatorOptions{}, "old", mustParseKey(t, "old", oldPublic)); err == nil {
+		t.Errorf("Expected a public key to be rejected as the signing key")
+	}
+
+	// Once the old key is dropped its tokens are rejected.
+	otherRSA, _ := rsaKeyPEM(t)
+	newOnly, err := auth.NewKeyRing(auth.ValidatorOptions{}, "new", mustParseKey(t, "new", otherRSA))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	if _, err := newOnly.ValidateJWT(oldToken); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("Expected unknown key error, got %v", err)
+	}
+}
+
+func TestKeyRingRejectsAlgorithmConfusion(t *testing.T) {
+	private, public := rsaKeyPEM(t)
+	ring, err := auth.NewKeyRing(auth.ValidatorOptions{}, "rsa-1", mustParseKey(t, "rsa-1", private))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	// Sign an HS256 token using the published public key as the secret.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   uuid.New().String(),
+	})
+	token.Header["kid"] = "rsa-1"
+	tokenString, err := token.SignedString(public)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	tokenUserID, err := ring.ValidateJWT(tokenString)
+	if err == nil {
+		t.Errorf("Expected token with mismatched alg to be rejected")
+	}
+	if tokenUserID != uuid.Nil {
+		t.Errorf("Token user ID should be empty")
+	}
+
+	// The HS256-only validator must not accept asymmetric tokens either.
+	rsaToken, err := ring.MakeJWT(uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	if _, err := auth.ValidateJWT(rsaToken, string(public)); err == nil {
+		t.Errorf("Expected RS256 token to be rejected by ValidateJWT")
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	rsaPEM, _ := rsaKeyPEM(t)
+	_, edPublic := ed25519KeyPEM(t)
+	if err := os.WriteFile(filepath.Join(dir, "2024-01.pem"), rsaPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2023-07.pem"), edPublic, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.LoadKeys(dir)
+	if err != nil {
+		t.Fatalf("Error loading keys: %v", err)
+	}
+	ring, err := auth.NewKeyRing(auth.ValidatorOptions{}, "2024-01", append(keys, auth.NewHMACKey("", []byte("secret")))...)
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+
+	jwks := ring.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 published keys, got %d", len(jwks.Keys))
+	}
+	if k := jwks.Keys[0]; k.Kid != "2023-07" || k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != "EdDSA" || k.X == "" {
+		t.Errorf("Unexpected Ed25519 JWK: %+v", k)
+	}
+	if k := jwks.Keys[1]; k.Kid != "2024-01" || k.Kty != "RSA" || k.Alg != "RS256" || k.N == "" || k.E != "AQAB" {
+		t.Errorf("Unexpected RSA JWK: %+v", k)
+	}
+}
+
+func TestParseKeyPEMRejectsSmallRSAKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Error generating RSA key: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if _, err := auth.ParseKeyPEM("small", data); err == nil {
+		t.Errorf("Expected 1024-bit RSA key to be rejected")
+	}
+}
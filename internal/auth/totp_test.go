@@ -0,0 +1,114 @@
+package auth_test
+
+import (
+	"encoding/base32"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFCVectors(t *testing.T) {
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		// The RFC lists 8-digit codes; these are their last 6 digits.
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		code, err := auth.TOTPCode(rfcSecret, time.Unix(tc.unix, 0))
+		if err != nil {
+			t.Fatalf("Error generating TOTP code: %v", err)
+		}
+		if code != tc.code {
+			t.Errorf("At %d expected %s, got %s", tc.unix, tc.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("Error generating TOTP secret: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	code, err := auth.TOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("Error generating TOTP code: %v", err)
+	}
+
+	step, err := auth.ValidateTOTP(secret, code, now, 0)
+	if err != nil {
+		t.Fatalf("Error validating TOTP code: %v", err)
+	}
+	if step != now.Unix()/30 {
+		t.Errorf("Expected step %d, got %d", now.Unix()/30, step)
+	}
+	if _, err := auth.ValidateTOTP(secret, code, now.Add(30*time.Second), 0); err != nil {
+		t.Errorf("Expected code from the previous step to be accepted: %v", err)
+	}
+	if _, err := auth.ValidateTOTP(secret, code, now.Add(2*time.Minute), 0); !errors.Is(err, auth.ErrInvalidTOTPCode) {
+		t.Errorf("Expected stale code to be rejected, got %v", err)
+	}
+	if _, err := auth.ValidateTOTP(secret, code, now, step); !errors.Is(err, auth.ErrInvalidTOTPCode) {
+		t.Errorf("Expected replayed code to be rejected, got %v", err)
+	}
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if _, err := auth.ValidateTOTP(secret, wrong, now, 0); !errors.Is(err, auth.ErrInvalidTOTPCode) {
+		t.Errorf("Expected wrong code to be rejected, got %v", err)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := auth.TOTPURI("Chirpy", "walt@example.com", rfcSecret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Error parsing URI: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Chirpy:walt@example.com" {
+		t.Errorf("Unexpected URI: %s", uri)
+	}
+	q := u.Query()
+	if q.Get("secret") != rfcSecret || q.Get("issuer") != "Chirpy" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("Unexpected URI parameters: %s", u.RawQuery)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := auth.GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("Error generating recovery codes: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || !auth.IsRecoveryCode(code) {
+			t.Errorf("Unexpected recovery code format: %q", code)
+		}
+		if seen[code] {
+			t.Errorf("Duplicate recovery code: %q", code)
+		}
+		seen[code] = true
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+		if got := auth.NormalizeRecoveryCode(" " + typed + " "); got != code {
+			t.Errorf("Expected %q to normalize to %q, got %q", typed, code, got)
+		}
+	}
+	for _, code := range []string{"", "123456", "abcde-fghi", "abcde-fghi1", "abcdefghijk"} {
+		if auth.IsRecoveryCode(code) {
+			t.Errorf("Expected %q not to be a recovery code", code)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	DefaultIssuer = "chirpy"
+
+	PurposeTwoFactorChallenge = "2fa_challenge"
+)
+
+var (
+	ErrTokenMalformed      = errors.New("token is malformed")
+	ErrTokenExpired        = errors.New("token has expired")
+	ErrTokenNotYetValid    = errors.New("token is not valid yet")
+	ErrInvalidIssuer       = errors.New("token has the wrong issuer")
+	ErrInvalidAudience     = errors.New("token has the wrong audience")
+	ErrInvalidSignature    = errors.New("token signature is invalid")
+	ErrAlgorithmNotAllowed = errors.New("token signing algorithm is not allowed")
+	ErrWrongPurpose        = errors.New("token is not meant for this use")
+)
+
+// ValidatorOptions controls which tokens a KeyRing accepts. Issuer and
+// Audience are also stamped on the tokens the ring issues.
+type ValidatorOptions struct {
+	// Issuer defaults to DefaultIssuer.
+	Issuer string
+	// Audience is only checked when set.
+	Audience string
+	// Algorithms defaults to the algorithms of the keys in the ring.
+	Algorithms []string
+	// Leeway is the clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
+func (opts ValidatorOptions) parserOptions() []jwt.ParserOption {
+	parserOpts := []jwt.ParserOption{
+		jwt.WithIssuer(opts.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	return parserOpts
+}
+
+func (opts ValidatorOptions) allows(alg string) bool {
+	return slices.Contains(opts.Algorithms, alg)
+}
+
+// classifyError wraps a jwt parse error in the matching sentinel so callers
+// can tell failures apart with errors.Is without depending on the jwt package.
+// The original error stays in the chain.
+func classifyError(err error) error {
+	var kind error
+	switch {
+	case errors.Is(err, ErrUnknownKey), errors.Is(err, ErrAlgorithmNotAllowed):
+		return err
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		kind = ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenExpired):
+		kind = ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		kind = ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		kind = ErrInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		kind = ErrInvalidAudience
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		kind = ErrInvalidSignature
+	default:
+		kind = ErrTokenMalformed
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
@@ -0,0 +1,223 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func signHS256(t *testing.T, claims jwt.RegisteredClaims, secret string) string {
+	t.Helper()
+	tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	return tokenString
+}
+
+func TestValidatorOptions(t *testing.T) {
+	secret := "my-super-secret-key"
+	now := time.Now()
+	valid := jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Audience:  jwt.ClaimStrings{"chirpy-api"},
+		Subject:   uuid.New().String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	opts := auth.ValidatorOptions{Audience: "chirpy-api", Leeway: 30 * time.Second}
+
+	for _, tc := range []struct {
+		name    string
+		opts    auth.ValidatorOptions
+		claims  func(c *jwt.RegisteredClaims)
+		secret  string
+		wantErr error
+	}{
+		{name: "valid", opts: opts},
+		{
+			name:    "expired",
+			opts:    opts,
+			claims:  func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) },
+			wantErr: auth.ErrTokenExpired,
+		},
+		{
+			name:   "expired within leeway",
+			opts:   opts,
+			claims: func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-10 * time.Second)) },
+		},
+		{
+			name:    "missing expiry",
+			opts:    opts,
+			claims:  func(c *jwt.RegisteredClaims) { c.ExpiresAt = nil },
+			wantErr: auth.ErrTokenMalformed,
+		},
+		{
+			name:    "issued in the future",
+			opts:    opts,
+			claims:  func(c *jwt.RegisteredClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(time.Minute)) },
+			wantErr: auth.ErrTokenNotYetValid,
+		},
+		{
+			name:    "wrong issuer",
+			opts:    opts,
+			claims:  func(c *jwt.RegisteredClaims) { c.Issuer = "someone-else" },
+			wantErr: auth.ErrInvalidIssuer,
+		},
+		{
+			name:    "wrong audience",
+			opts:    opts,
+			claims:  func(c *jwt.RegisteredClaims) { c.Audience = jwt.ClaimStrings{"other-api"} },
+			wantErr: auth.ErrInvalidAudience,
+		},
+		{
+			name:    "bad signature",
+			opts:    opts,
+			secret:  "wrong-secret",
+			wantErr: auth.ErrInvalidSignature,
+		},
+		{
+			name:    "invalid subject",
+			opts:    opts,
+			claims:  func(c *jwt.RegisteredClaims) { c.Subject = "not-a-uuid" },
+			wantErr: auth.ErrTokenMalformed,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ring, err := auth.NewKeyRing(tc.opts, "", auth.NewHMACKey("", []byte(secret)))
+			if err != nil {
+				t.Fatalf("Error creating key ring: %v", err)
+			}
+			claims := valid
+			if tc.claims != nil {
+				tc.claims(&claims)
+			}
+			signingSecret := secret
+			if tc.secret != "" {
+				signingSecret = tc.secret
+			}
+			tokenUserID, err := ring.ValidateJWT(signHS256(t, claims, signingSecret))
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Errorf("Error validating JWT token: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Expected %v, got %v", tc.wantErr, err)
+			}
+			if tokenUserID != uuid.Nil {
+				t.Errorf("Token user ID should be empty")
+			}
+		})
+	}
+}
+
+func TestValidatorRejectsDisallowedAlgorithm(t *testing.T) {
+	edPEM, _ := ed25519KeyPEM(t)
+	edKey := mustParseKey(t, "ed-1", edPEM)
+	hmacKey := auth.NewHMACKey("", []byte("my-super-secret-key"))
+
+	issuer, err := auth.NewKeyRing(auth.ValidatorOptions{}, "", hmacKey)
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	tokenString, err := issuer.MakeJWT(uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+
+	ring, err := auth.NewKeyRing(auth.ValidatorOptions{Algorithms: []string{"EdDSA"}}, "ed-1", edKey, hmacKey)
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	if _, err := ring.ValidateJWT(tokenString); !errors.Is(err, auth.ErrAlgorithmNotAllowed) {
+		t.Errorf("Expected %v, got %v", auth.ErrAlgorithmNotAllowed, err)
+	}
+	if _, err := auth.NewKeyRing(auth.ValidatorOptions{Algorithms: []string{"EdDSA"}}, "", hmacKey); !errors.Is(err, auth.ErrAlgorithmNotAllowed) {
+		t.Errorf("Expected a signing key with a disallowed algorithm to be rejected, got %v", err)
+	}
+}
+
+func TestKeyRingStampsIssuerAndAudience(t *testing.T) {
+	opts := auth.ValidatorOptions{Issuer: "chirpy-staging", Audience: "chirpy-api"}
+	ring, err := auth.NewKeyRing(opts, "", auth.NewHMACKey("", []byte("my-super-secret-key")))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	tokenString, err := ring.MakeJWT(uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	if _, err := ring.ValidateJWT(tokenString); err != nil {
+		t.Errorf("Error validating JWT token: %v", err)
+	}
+	if _, err := auth.ValidateJWT(tokenString, "my-super-secret-key"); !errors.Is(err, auth.ErrInvalidIssuer) {
+		t.Errorf("Expected %v, got %v", auth.ErrInvalidIssuer, err)
+	}
+}
+
+func TestChallengeTokensAreNotAccessTokens(t *testing.T) {
+	ring, err := auth.NewKeyRing(auth.ValidatorOptions{}, "", auth.NewHMACKey("", []byte("my-super-secret-key")))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	userID := uuid.New()
+	challenge, err := ring.MakeChallengeJWT(userID, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	if _, err := ring.ValidateJWT(challenge); !errors.Is(err, auth.ErrWrongPurpose) {
+		t.Errorf("Expected %v, got %v", auth.ErrWrongPurpose, err)
+	}
+	tokenUserID, err := ring.ValidateChallengeJWT(challenge)
+	if err != nil {
+		t.Fatalf("Error validating challenge token: %v", err)
+	}
+	if tokenUserID != userID {
+		t.Errorf("Token user ID does not match expected user ID")
+	}
+
+	access, err := ring.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	if _, err := ring.ValidateChallengeJWT(access); !errors.Is(err, auth.ErrWrongPurpose) {
+		t.Errorf("Expected %v, got %v", auth.ErrWrongPurpose, err)
+	}
+}
+
+func TestAccessTokenRole(t *testing.T) {
+	ring, err := auth.NewKeyRing(auth.ValidatorOptions{}, "", auth.NewHMACKey("", []byte("my-super-secret-key")))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	userID := uuid.New()
+	tokenString, err := ring.MakeAccessJWT(auth.AccessClaims{UserID: userID, Role: "admin"}, time.Hour)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	access, err := ring.ValidateAccessJWT(tokenString)
+	if err != nil {
+		t.Fatalf("Error validating JWT token: %v", err)
+	}
+	if access.UserID != userID || access.Role != "admin" {
+		t.Errorf("Expected %v with role admin, got %+v", userID, access)
+	}
+
+	tokenString, err = ring.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("Error generating JWT token: %v", err)
+	}
+	access, err = ring.ValidateAccessJWT(tokenString)
+	if err != nil {
+		t.Fatalf("Error validating JWT token: %v", err)
+	}
+	if access.Role != "" {
+		t.Errorf("Expected no role, got %q", access.Role)
+	}
+}
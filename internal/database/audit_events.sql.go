@@ -0,0 +1,35 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_events.sql
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, type, user_id, ip, details)
+VALUES (gen_random_uuid(), $1, $2, $3, $4)
+`
+
+type CreateAuditEventParams struct {
+	Type    string          `json:"type"`
+	UserID  uuid.NullUUID   `json:"user_id"`
+	Ip      string          `json:"ip"`
+	Details json.RawMessage `json:"details"`
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.ExecContext(ctx, createAuditEvent,
+		arg.Type,
+		arg.UserID,
+		arg.Ip,
+		arg.Details,
+	)
+	return err
+}
@@ -0,0 +1,259 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: blocks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const blockUser = `-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type BlockUserParams struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+}
+
+func (q *Queries) BlockUser(ctx context.Context, arg BlockUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, blockUser, arg.BlockerID, arg.BlockedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getBlockingHandles = `-- name: GetBlockingHandles :many
+SELECT lower(users.handle)::text FROM blocks
+JOIN users ON users.id = blocks.blocker_id
+WHERE blocks.blocked_id = $1
+  AND lower(users.handle) = ANY($2::text[])
+`
+
+type GetBlockingHandlesParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Handles []string  `json:"handles"`
+}
+
+// Returns which of the given lower-cased handles belong to users who blocked
+// user_id.
+func (q *Queries) GetBlockingHandles(ctx context.Context, arg GetBlockingHandlesParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getBlockingHandles, arg.UserID, pq.Array(arg.Handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var column_1 string
+		if err := rows.Scan(&column_1); err != nil {
+			return nil, err
+		}
+		items = append(items, column_1)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getBlocks = `-- name: GetBlocks :many
+SELECT blocker_id, blocked_id, created_at FROM blocks
+WHERE blocker_id = $1
+  AND ($2::timestamp IS NULL
+   OR (created_at, blocked_id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, blocked_id DESC
+LIMIT $4
+`
+
+type GetBlocksParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetBlocks(ctx context.Context, arg GetBlocksParams) ([]Block, error) {
+	rows, err := q.db.QueryContext(ctx, getBlocks,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Block
+	for rows.Next() {
+		var i Block
+		if err := rows.Scan(&i.BlockerID, &i.BlockedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMutes = `-- name: GetMutes :many
+SELECT muter_id, muted_id, created_at FROM mutes
+WHERE muter_id = $1
+  AND ($2::timestamp IS NULL
+   OR (created_at, muted_id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, muted_id DESC
+LIMIT $4
+`
+
+type GetMutesParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetMutes(ctx context.Context, arg GetMutesParams) ([]Mute, error) {
+	rows, err := q.db.QueryContext(ctx, getMutes,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Mute
+	for rows.Next() {
+		var i Mute
+		if err := rows.Scan(&i.MuterID, &i.MutedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isBlockedEitherWay = `-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2)
+       OR (blocker_id = $2 AND blocked_id = $1)
+)::boolean
+`
+
+type IsBlockedEitherWayParams struct {
+	A uuid.UUID `json:"a"`
+	B uuid.UUID `json:"b"`
+}
+
+func (q *Queries) IsBlockedEitherWay(ctx context.Context, arg IsBlockedEitherWayParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isBlockedEitherWay, arg.A, arg.B)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const lockUserPair = `-- name: LockUserPair :many
+SELECT id FROM users
+WHERE id IN ($1::uuid, $2::uuid)
+ORDER BY id
+FOR UPDATE
+`
+
+type LockUserPairParams struct {
+	A uuid.UUID `json:"a"`
+	B uuid.UUID `json:"b"`
+}
+
+// Locks both users in id order, so that a follow and a block between them
+// can't interleave.
+func (q *Queries) LockUserPair(ctx context.Context, arg LockUserPairParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, lockUserPair, arg.A, arg.B)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const muteUser = `-- name: MuteUser :exec
+INSERT INTO mutes (muter_id, muted_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type MuteUserParams struct {
+	MuterID uuid.UUID `json:"muter_id"`
+	MutedID uuid.UUID `json:"muted_id"`
+}
+
+func (q *Queries) MuteUser(ctx context.Context, arg MuteUserParams) error {
+	_, err := q.db.ExecContext(ctx, muteUser, arg.MuterID, arg.MutedID)
+	return err
+}
+
+const unblockUser = `-- name: UnblockUser :exec
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2
+`
+
+type UnblockUserParams struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+}
+
+func (q *Queries) UnblockUser(ctx context.Context, arg UnblockUserParams) error {
+	_, err := q.db.ExecContext(ctx, unblockUser, arg.BlockerID, arg.BlockedID)
+	return err
+}
+
+const unmuteUser = `-- name: UnmuteUser :exec
+DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2
+`
+
+type UnmuteUserParams struct {
+	MuterID uuid.UUID `json:"muter_id"`
+	MutedID uuid.UUID `json:"muted_id"`
+}
+
+func (q *Queries) UnmuteUser(ctx context.Context, arg UnmuteUserParams) error {
+	_, err := q.db.ExecContext(ctx, unmuteUser, arg.MuterID, arg.MutedID)
+	return err
+}
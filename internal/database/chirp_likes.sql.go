@@ -0,0 +1,172 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: chirp_likes.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const getChirpsLikedByUser = `-- name: GetChirpsLikedByUser :many
+SELECT chirps.id, chirps.user_id, chirps.body, chirps.created_at, chirps.updated_at, chirps.search_vector, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_of, chirps.quote_of, chirp_likes.created_at AS liked_at
+FROM chirp_likes
+JOIN chirps ON chirps.id = chirp_likes.chirp_id
+WHERE chirp_likes.user_id = $1
+  AND chirps.deleted_at IS NULL
+  AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $2::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $2::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = original.user_id)))
+  AND ($3::timestamp IS NULL
+   OR (chirp_likes.created_at, chirp_likes.chirp_id) < ($3::timestamp, $4::uuid))
+ORDER BY chirp_likes.created_at DESC, chirp_likes.chirp_id DESC
+LIMIT $5
+`
+
+type GetChirpsLikedByUserParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	ViewerID        uuid.NullUUID `json:"viewer_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+type GetChirpsLikedByUserRow struct {
+	Chirp   Chirp     `json:"chirp"`
+	LikedAt time.Time `json:"liked_at"`
+}
+
+func (q *Queries) GetChirpsLikedByUser(ctx context.Context, arg GetChirpsLikedByUserParams) ([]GetChirpsLikedByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsLikedByUser,
+		arg.UserID,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsLikedByUserRow
+	for rows.Next() {
+		var i GetChirpsLikedByUserRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.UserID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.SearchVector,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpOf,
+			&i.Chirp.QuoteOf,
+			&i.LikedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLikedChirpIDs = `-- name: GetLikedChirpIDs :many
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = ANY($2::uuid[])
+`
+
+type GetLikedChirpIDsParams struct {
+	UserID   uuid.UUID   `json:"user_id"`
+	ChirpIds []uuid.UUID `json:"chirp_ids"`
+}
+
+func (q *Queries) GetLikedChirpIDs(ctx context.Context, arg GetLikedChirpIDsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getLikedChirpIDs, arg.UserID, pq.Array(arg.ChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var chirp_id uuid.UUID
+		if err := rows.Scan(&chirp_id); err != nil {
+			return nil, err
+		}
+		items = append(items, chirp_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const likeChirp = `-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type LikeChirpParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) LikeChirp(ctx context.Context, arg LikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, likeChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unlikeChirp = `-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes WHERE user_id = $1 AND chirp_id = $2
+`
+
+type UnlikeChirpParams struct {
+	UserID  uuid.UUID `json:"user_id"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (q *Queries) UnlikeChirp(ctx context.Context, arg UnlikeChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unlikeChirp, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,23 +7,64 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, user_id, body)
-VALUES (gen_random_uuid(), $1, $2)
-RETURNING id, user_id, body, created_at, updated_at
+INSERT INTO chirps (id, user_id, body, in_reply_to, quote_of)
+VALUES (gen_random_uuid(), $1, $2, $3, $4)
+RETURNING id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of
 `
 
 type CreateChirpParams struct {
-	UserID uuid.UUID `json:"user_id"`
-	Body   string    `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Body      string        `json:"body"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.UserID,
+		arg.Body,
+		arg.InReplyTo,
+		arg.QuoteOf,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+	)
+	return i, err
+}
+
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps (id, user_id, body, rechirp_of)
+VALUES (gen_random_uuid(), $1, '', $2::uuid)
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
+RETURNING id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of
+`
+
+type CreateRechirpParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	RechirpOf uuid.UUID `json:"rechirp_of"`
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RechirpOf)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -31,10 +72,26 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
 	)
 	return i, err
 }
 
+const decrementLikeCount = `-- name: DecrementLikeCount :exec
+UPDATE chirps SET like_count = like_count - 1 WHERE id = $1
+`
+
+func (q *Queries) DecrementLikeCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementLikeCount, id)
+	return err
+}
+
 const deleteChirp = `-- name: DeleteChirp :exec
 DELETE FROM chirps WHERE id = $1
 `
@@ -44,12 +101,149 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteRechirp = `-- name: DeleteRechirp :exec
+DELETE FROM chirps WHERE user_id = $1 AND rechirp_of = $2::uuid
+`
+
+type DeleteRechirpParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	RechirpOf uuid.UUID `json:"rechirp_of"`
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) error {
+	_, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.RechirpOf)
+	return err
+}
+
+const deleteRechirpsOf = `-- name: DeleteRechirpsOf :exec
+DELETE FROM chirps WHERE rechirp_of = $1::uuid
+`
+
+func (q *Queries) DeleteRechirpsOf(ctx context.Context, rechirpOf uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRechirpsOf, rechirpOf)
+	return err
+}
+
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, user_id, body, created_at, updated_at FROM chirps ORDER BY created_at ASC
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps
+WHERE deleted_at IS NULL
+  AND (chirps.user_id = $1::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $1::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $1::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = original.user_id)))
+  AND ($2::timestamp IS NULL
+   OR (created_at, id) > ($2::timestamp, $3::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $4
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+type GetAllChirpsParams struct {
+	ViewerID        uuid.NullUUID `json:"viewer_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetAllChirps(ctx context.Context, arg GetAllChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllChirpsDesc = `-- name: GetAllChirpsDesc :many
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps
+WHERE deleted_at IS NULL
+  AND (chirps.user_id = $1::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $1::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $1::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = original.user_id)))
+  AND ($2::timestamp IS NULL
+   OR (created_at, id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetAllChirpsDescParams struct {
+	ViewerID        uuid.NullUUID `json:"viewer_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetAllChirpsDesc(ctx context.Context, arg GetAllChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirpsDesc,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +257,78 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.in_reply_to, 1 AS depth
+    FROM chirps parent
+    JOIN chirps child ON child.in_reply_to = parent.id
+    WHERE child.id = $2::uuid
+  UNION ALL
+    SELECT parent.id, parent.in_reply_to, ancestors.depth + 1
+    FROM chirps parent
+    JOIN ancestors ON ancestors.in_reply_to = parent.id
+)
+SELECT chirps.id, chirps.user_id, chirps.body, chirps.created_at, chirps.updated_at, chirps.search_vector, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_of, chirps.quote_of FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+WHERE (chirps.user_id = $1::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id)
+ORDER BY ancestors.depth DESC
+`
+
+type GetChirpAncestorsParams struct {
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+	ID       uuid.UUID     `json:"id"`
+}
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, arg GetChirpAncestorsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, arg.ViewerID, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
 		); err != nil {
 			return nil, err
 		}
@@ -78,7 +344,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, user_id, body, created_at, updated_at FROM chirps WHERE id = $1
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -90,16 +356,179 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+	)
+	return i, err
+}
+
+const getChirpByIDForUpdate = `-- name: GetChirpByIDForUpdate :one
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetChirpByIDForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
 	)
 	return i, err
 }
 
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT chirps.id, 1 AS depth,
+        ARRAY[to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text] AS path
+    FROM chirps
+    WHERE chirps.in_reply_to = $4::uuid
+      AND (chirps.user_id = $1::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+      AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+           OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid))
+  UNION ALL
+    SELECT chirps.id, descendants.depth + 1,
+        descendants.path || (to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text)
+    FROM chirps
+    JOIN descendants ON chirps.in_reply_to = descendants.id
+    WHERE (chirps.user_id = $1::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+      AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = $1::uuid AND blocks.blocked_id = chirps.user_id)
+           OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1::uuid))
+)
+SELECT chirps.id, chirps.user_id, chirps.body, chirps.created_at, chirps.updated_at, chirps.search_vector, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_of, chirps.quote_of, descendants.depth::int AS depth
+FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+WHERE NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $1::uuid AND mutes.muted_id = chirps.user_id)
+  AND ($2::uuid IS NULL
+   OR descendants.path > (SELECT path FROM descendants WHERE id = $2::uuid))
+ORDER BY descendants.path
+LIMIT $3
+`
+
+type GetChirpDescendantsParams struct {
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+	CursorID uuid.NullUUID `json:"cursor_id"`
+	Limit    int32         `json:"limit"`
+	ID       uuid.UUID     `json:"id"`
+}
+
+type GetChirpDescendantsRow struct {
+	Chirp Chirp `json:"chirp"`
+	Depth int32 `json:"depth"`
+}
+
+// Replies the viewer may not see are left out together with the replies
+// below them. Replies by muted users are left out on their own, because
+// the replies below them can be from anyone.
+func (q *Queries) GetChirpDescendants(ctx context.Context, arg GetChirpDescendantsParams) ([]GetChirpDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants,
+		arg.ViewerID,
+		arg.CursorID,
+		arg.Limit,
+		arg.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpDescendantsRow
+	for rows.Next() {
+		var i GetChirpDescendantsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.UserID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.SearchVector,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpOf,
+			&i.Chirp.QuoteOf,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, user_id, body, created_at, updated_at FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps
+WHERE chirps.user_id = $1
+  AND deleted_at IS NULL
+  AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $2::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $2::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = original.user_id)))
+  AND ($3::timestamp IS NULL
+   OR (created_at, id) > ($3::timestamp, $4::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $5
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+type GetChirpsByAuthorParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	ViewerID        uuid.NullUUID `json:"viewer_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetChirpsByAuthor(ctx context.Context, arg GetChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor,
+		arg.UserID,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +542,13 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
 		); err != nil {
 			return nil, err
 		}
@@ -126,3 +562,307 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const getChirpsByAuthorDesc = `-- name: GetChirpsByAuthorDesc :many
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps
+WHERE chirps.user_id = $1
+  AND deleted_at IS NULL
+  AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $2::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $2::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = original.user_id)))
+  AND ($3::timestamp IS NULL
+   OR (created_at, id) < ($3::timestamp, $4::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type GetChirpsByAuthorDescParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	ViewerID        uuid.NullUUID `json:"viewer_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetChirpsByAuthorDesc(ctx context.Context, arg GetChirpsByAuthorDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorDesc,
+		arg.UserID,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps
+WHERE id = ANY($1::uuid[])
+  AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id)
+`
+
+type GetChirpsByIDsParams struct {
+	Ids      []uuid.UUID   `json:"ids"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, arg GetChirpsByIDsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(arg.Ids), arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRechirp = `-- name: GetRechirp :one
+SELECT id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of FROM chirps WHERE user_id = $1 AND rechirp_of = $2::uuid
+`
+
+type GetRechirpParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	RechirpOf uuid.UUID `json:"rechirp_of"`
+}
+
+func (q *Queries) GetRechirp(ctx context.Context, arg GetRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getRechirp, arg.UserID, arg.RechirpOf)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+	)
+	return i, err
+}
+
+const incrementLikeCount = `-- name: IncrementLikeCount :exec
+UPDATE chirps SET like_count = like_count + 1 WHERE id = $1
+`
+
+func (q *Queries) IncrementLikeCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, incrementLikeCount, id)
+	return err
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.user_id, chirps.body, chirps.created_at, chirps.updated_at, chirps.search_vector, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_of, chirps.quote_of, ts_rank(chirps.search_vector, query)::real AS rank
+FROM chirps, to_tsquery('english', $1) AS query
+WHERE chirps.search_vector @@ query
+  AND chirps.deleted_at IS NULL
+  AND ($2::uuid IS NULL OR chirps.user_id = $2::uuid)
+  AND (chirps.user_id = $3::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $3::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $3::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $3::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $3::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $3::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $3::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $3::uuid AND mutes.muted_id = original.user_id)))
+  AND ($4::real IS NULL
+   OR (ts_rank(chirps.search_vector, query), chirps.id) < ($4::real, $5::uuid))
+ORDER BY rank DESC, chirps.id DESC
+LIMIT $6
+`
+
+type SearchChirpsParams struct {
+	Query      string          `json:"query"`
+	AuthorID   uuid.NullUUID   `json:"author_id"`
+	ViewerID   uuid.NullUUID   `json:"viewer_id"`
+	CursorRank sql.NullFloat64 `json:"cursor_rank"`
+	CursorID   uuid.NullUUID   `json:"cursor_id"`
+	Limit      int32           `json:"limit"`
+}
+
+type SearchChirpsRow struct {
+	Chirp Chirp   `json:"chirp"`
+	Rank  float32 `json:"rank"`
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.ViewerID,
+		arg.CursorRank,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.UserID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.SearchVector,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpOf,
+			&i.Chirp.QuoteOf,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneChirp = `-- name: TombstoneChirp :exec
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, tombstoneChirp, id)
+	return err
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1 RETURNING id, user_id, body, created_at, updated_at, search_vector, in_reply_to, reply_count, deleted_at, like_count, rechirp_of, quote_of
+`
+
+type UpdateChirpParams struct {
+	ID   uuid.UUID `json:"id"`
+	Body string    `json:"body"`
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+	)
+	return i, err
+}
@@ -0,0 +1,229 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: follows.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const deleteFollowsBetween = `-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = $1 AND followee_id = $2)
+   OR (follower_id = $2 AND followee_id = $1)
+`
+
+type DeleteFollowsBetweenParams struct {
+	A uuid.UUID `json:"a"`
+	B uuid.UUID `json:"b"`
+}
+
+func (q *Queries) DeleteFollowsBetween(ctx context.Context, arg DeleteFollowsBetweenParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFollowsBetween, arg.A, arg.B)
+	return err
+}
+
+const followUser = `-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type FollowUserParams struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+func (q *Queries) FollowUser(ctx context.Context, arg FollowUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, followUser, arg.FollowerID, arg.FolloweeID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFollowers = `-- name: GetFollowers :many
+SELECT follower_id, followee_id, created_at FROM follows
+WHERE followee_id = $1
+  AND ($2::timestamp IS NULL
+   OR (created_at, follower_id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT $4
+`
+
+type GetFollowersParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetFollowers(ctx context.Context, arg GetFollowersParams) ([]Follow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowers,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Follow
+	for rows.Next() {
+		var i Follow
+		if err := rows.Scan(&i.FollowerID, &i.FolloweeID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFollowing = `-- name: GetFollowing :many
+SELECT follower_id, followee_id, created_at FROM follows
+WHERE follower_id = $1
+  AND ($2::timestamp IS NULL
+   OR (created_at, followee_id) < ($2::timestamp, $3::uuid))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT $4
+`
+
+type GetFollowingParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetFollowing(ctx context.Context, arg GetFollowingParams) ([]Follow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowing,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Follow
+	for rows.Next() {
+		var i Follow
+		if err := rows.Scan(&i.FollowerID, &i.FolloweeID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTimeline = `-- name: GetTimeline :many
+SELECT chirps.id, chirps.user_id, chirps.body, chirps.created_at, chirps.updated_at, chirps.search_vector, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_of, chirps.quote_of FROM chirps
+JOIN follows ON chirps.user_id = follows.followee_id
+WHERE follows.follower_id = $1
+  AND chirps.deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL)
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $1))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $1 AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $1
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $1 AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $1))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $1 AND mutes.muted_id = original.user_id)))
+  AND ($2::timestamp IS NULL
+   OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type GetTimelineParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetTimeline(ctx context.Context, arg GetTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTimeline,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const unfollowUser = `-- name: UnfollowUser :exec
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2
+`
+
+type UnfollowUserParams struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+func (q *Queries) UnfollowUser(ctx context.Context, arg UnfollowUserParams) error {
+	_, err := q.db.ExecContext(ctx, unfollowUser, arg.FollowerID, arg.FolloweeID)
+	return err
+}
@@ -0,0 +1,183 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: hashtags.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const addChirpHashtag = `-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING
+`
+
+type AddChirpHashtagParams struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	HashtagID uuid.UUID `json:"hashtag_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (q *Queries) AddChirpHashtag(ctx context.Context, arg AddChirpHashtagParams) error {
+	_, err := q.db.ExecContext(ctx, addChirpHashtag, arg.ChirpID, arg.HashtagID, arg.CreatedAt)
+	return err
+}
+
+const deleteChirpHashtags = `-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags WHERE chirp_id = $1
+`
+
+func (q *Queries) DeleteChirpHashtags(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpHashtags, chirpID)
+	return err
+}
+
+const getChirpsByHashtag = `-- name: GetChirpsByHashtag :many
+SELECT chirps.id, chirps.user_id, chirps.body, chirps.created_at, chirps.updated_at, chirps.search_vector, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_of, chirps.quote_of FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = $1
+  AND chirps.deleted_at IS NULL
+  AND (chirps.user_id = $2::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = $2::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM $2::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = $2::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = $2::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = $2::uuid AND mutes.muted_id = original.user_id)))
+  AND ($3::timestamp IS NULL
+   OR (chirp_hashtags.created_at, chirp_hashtags.chirp_id) < ($3::timestamp, $4::uuid))
+ORDER BY chirp_hashtags.created_at DESC, chirp_hashtags.chirp_id DESC
+LIMIT $5
+`
+
+type GetChirpsByHashtagParams struct {
+	Tag             string        `json:"tag"`
+	ViewerID        uuid.NullUUID `json:"viewer_id"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetChirpsByHashtag(ctx context.Context, arg GetChirpsByHashtagParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByHashtag,
+		arg.Tag,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrendingHashtags = `-- name: GetTrendingHashtags :many
+SELECT hashtags.tag, COUNT(*) AS chirp_count
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+JOIN users ON users.id = chirps.user_id
+WHERE chirp_hashtags.created_at > NOW() - $1::int * INTERVAL '1 second'
+  AND users.shadow_banned_at IS NULL
+GROUP BY hashtags.tag
+ORDER BY chirp_count DESC, hashtags.tag ASC
+LIMIT $2
+`
+
+type GetTrendingHashtagsParams struct {
+	WindowSeconds int32 `json:"window_seconds"`
+	Limit         int32 `json:"limit"`
+}
+
+type GetTrendingHashtagsRow struct {
+	Tag        string `json:"tag"`
+	ChirpCount int64  `json:"chirp_count"`
+}
+
+// Chirps of shadow-banned users don't count, so they can't push a tag up.
+func (q *Queries) GetTrendingHashtags(ctx context.Context, arg GetTrendingHashtagsParams) ([]GetTrendingHashtagsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTrendingHashtags, arg.WindowSeconds, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTrendingHashtagsRow
+	for rows.Next() {
+		var i GetTrendingHashtagsRow
+		if err := rows.Scan(&i.Tag, &i.ChirpCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertHashtag = `-- name: UpsertHashtag :one
+INSERT INTO hashtags (id, tag)
+VALUES (gen_random_uuid(), $1)
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING id, tag, created_at
+`
+
+func (q *Queries) UpsertHashtag(ctx context.Context, tag string) (Hashtag, error) {
+	row := q.db.QueryRowContext(ctx, upsertHashtag, tag)
+	var i Hashtag
+	err := row.Scan(&i.ID, &i.Tag, &i.CreatedAt)
+	return i, err
+}
@@ -0,0 +1,102 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: login_attempts.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const deleteExpiredLoginAttempts = `-- name: DeleteExpiredLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE (last_failure_at IS NULL OR last_failure_at < $1::timestamp)
+  AND (locked_until IS NULL OR locked_until < $2::timestamp)
+`
+
+type DeleteExpiredLoginAttemptsParams struct {
+	FailedBefore time.Time `json:"failed_before"`
+	Now          time.Time `json:"now"`
+}
+
+func (q *Queries) DeleteExpiredLoginAttempts(ctx context.Context, arg DeleteExpiredLoginAttemptsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredLoginAttempts, arg.FailedBefore, arg.Now)
+	return err
+}
+
+const deleteLoginAttempt = `-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts WHERE key = $1
+`
+
+func (q *Queries) DeleteLoginAttempt(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, deleteLoginAttempt, key)
+	return err
+}
+
+const ensureLoginAttempt = `-- name: EnsureLoginAttempt :exec
+INSERT INTO login_attempts (key) VALUES ($1)
+ON CONFLICT (key) DO NOTHING
+`
+
+func (q *Queries) EnsureLoginAttempt(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, ensureLoginAttempt, key)
+	return err
+}
+
+const getLoginAttempt = `-- name: GetLoginAttempt :one
+SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1
+`
+
+func (q *Queries) GetLoginAttempt(ctx context.Context, key string) (LoginAttempt, error) {
+	row := q.db.QueryRowContext(ctx, getLoginAttempt, key)
+	var i LoginAttempt
+	err := row.Scan(
+		&i.Key,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const getLoginAttemptForUpdate = `-- name: GetLoginAttemptForUpdate :one
+SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1 FOR UPDATE
+`
+
+func (q *Queries) GetLoginAttemptForUpdate(ctx context.Context, key string) (LoginAttempt, error) {
+	row := q.db.QueryRowContext(ctx, getLoginAttemptForUpdate, key)
+	var i LoginAttempt
+	err := row.Scan(
+		&i.Key,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const updateLoginAttempt = `-- name: UpdateLoginAttempt :exec
+UPDATE login_attempts
+SET failures = $2, last_failure_at = $3, locked_until = $4
+WHERE key = $1
+`
+
+type UpdateLoginAttemptParams struct {
+	Key           string       `json:"key"`
+	Failures      int32        `json:"failures"`
+	LastFailureAt sql.NullTime `json:"last_failure_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
+func (q *Queries) UpdateLoginAttempt(ctx context.Context, arg UpdateLoginAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, updateLoginAttempt,
+		arg.Key,
+		arg.Failures,
+		arg.LastFailureAt,
+		arg.LockedUntil,
+	)
+	return err
+}
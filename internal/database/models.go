@@ -6,33 +6,197 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Type      string          `json:"type"`
+	UserID    uuid.NullUUID   `json:"user_id"`
+	Ip        string          `json:"ip"`
+	Details   json.RawMessage `json:"details"`
+}
+
+type Block struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
+	ID           uuid.UUID     `json:"id"`
+	UserID       uuid.UUID     `json:"user_id"`
+	Body         string        `json:"body"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	SearchVector string        `json:"-"`
+	InReplyTo    uuid.NullUUID `json:"in_reply_to"`
+	ReplyCount   int32         `json:"reply_count"`
+	DeletedAt    *time.Time    `json:"deleted_at"`
+	LikeCount    int32         `json:"like_count"`
+	RechirpOf    uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf      uuid.NullUUID `json:"quote_of"`
+}
+
+type ChirpFlag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Terms     []string  `json:"terms"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	HashtagID uuid.UUID `json:"hashtag_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChirpLike struct {
 	UserID    uuid.UUID `json:"user_id"`
-	Body      string    `json:"body"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type RefreshToken struct {
-	Token     string       `json:"token"`
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type EmailVerificationToken struct {
+	TokenHash string       `json:"token_hash"`
 	UserID    uuid.UUID    `json:"user_id"`
+	Email     string       `json:"email"`
+	CreatedAt time.Time    `json:"created_at"`
 	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Hashtag struct {
+	ID        uuid.UUID `json:"id"`
+	Tag       string    `json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LoginAttempt struct {
+	Key           string       `json:"key"`
+	Failures      int32        `json:"failures"`
+	LastFailureAt sql.NullTime `json:"last_failure_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
+type ModerationTerm struct {
+	Term      string    `json:"term"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Mute struct {
+	MuterID   uuid.UUID `json:"muter_id"`
+	MutedID   uuid.UUID `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Notification struct {
+	ID        uuid.UUID     `json:"id"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ActorID   uuid.UUID     `json:"actor_id"`
+	Type      string        `json:"type"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	CreatedAt time.Time     `json:"created_at"`
+	ReadAt    *time.Time    `json:"read_at"`
+}
+
+type PasswordResetToken struct {
+	TokenHash string       `json:"token_hash"`
+	UserID    uuid.UUID    `json:"user_id"`
 	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID    `json:"id"`
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"-"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RefreshToken struct {
+	Token      string       `json:"token"`
+	UserID     uuid.UUID    `json:"user_id"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	FamilyID   uuid.UUID    `json:"family_id"`
+	UserAgent  string       `json:"user_agent"`
+	Ip         string       `json:"ip"`
+	LastUsedAt time.Time    `json:"last_used_at"`
+}
+
+type Report struct {
+	ID             uuid.UUID     `json:"id"`
+	ChirpID        uuid.NullUUID `json:"chirp_id"`
+	ChirpAuthorID  uuid.UUID     `json:"chirp_author_id"`
+	ChirpBody      string        `json:"chirp_body"`
+	ReporterID     uuid.UUID     `json:"reporter_id"`
+	Reason         string        `json:"reason"`
+	Details        string        `json:"details"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Status         string        `json:"status"`
+	Resolution     *string       `json:"resolution"`
+	ResolutionNote string        `json:"resolution_note"`
+	ResolvedBy     uuid.NullUUID `json:"resolved_by"`
+	ResolvedAt     *time.Time    `json:"resolved_at"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"-"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID              uuid.UUID      `json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	Email           string         `json:"email"`
+	HashedPassword  string         `json:"-"`
+	IsChirpyRed     bool           `json:"is_chirpy_red"`
+	Handle          *string        `json:"handle"`
+	DisplayName     string         `json:"display_name"`
+	Bio             string         `json:"bio"`
+	TotpSecret      sql.NullString `json:"-"`
+	TotpEnabledAt   *time.Time     `json:"totp_enabled_at"`
+	TotpLastStep    int64          `json:"-"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	PendingEmail    *string        `json:"pending_email"`
+	Role            string         `json:"role"`
+	SuspendedUntil  *time.Time     `json:"suspended_until"`
+	ShadowBannedAt  *time.Time     `json:"-"`
+}
+
+type WebhookEvent struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	ReceivedAt time.Time `json:"received_at"`
 }
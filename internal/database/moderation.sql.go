@@ -0,0 +1,128 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: moderation.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const flagChirp = `-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, terms)
+VALUES ($1, $2)
+ON CONFLICT (chirp_id) DO UPDATE SET terms = EXCLUDED.terms, created_at = NOW()
+`
+
+type FlagChirpParams struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Terms   []string  `json:"terms"`
+}
+
+func (q *Queries) FlagChirp(ctx context.Context, arg FlagChirpParams) error {
+	_, err := q.db.ExecContext(ctx, flagChirp, arg.ChirpID, pq.Array(arg.Terms))
+	return err
+}
+
+const getFlaggedChirps = `-- name: GetFlaggedChirps :many
+SELECT chirps.id, chirps.user_id, chirps.body, chirps.created_at, chirps.updated_at, chirps.search_vector, chirps.in_reply_to, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_of, chirps.quote_of, chirp_flags.terms, chirp_flags.created_at AS flagged_at
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+WHERE chirps.deleted_at IS NULL
+  AND ($1::timestamp IS NULL
+   OR (chirp_flags.created_at, chirp_flags.chirp_id) > ($1::timestamp, $2::uuid))
+ORDER BY chirp_flags.created_at, chirp_flags.chirp_id
+LIMIT $3
+`
+
+type GetFlaggedChirpsParams struct {
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+type GetFlaggedChirpsRow struct {
+	Chirp     Chirp     `json:"chirp"`
+	Terms     []string  `json:"terms"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+func (q *Queries) GetFlaggedChirps(ctx context.Context, arg GetFlaggedChirpsParams) ([]GetFlaggedChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFlaggedChirps, arg.CursorCreatedAt, arg.CursorID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFlaggedChirpsRow
+	for rows.Next() {
+		var i GetFlaggedChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.UserID,
+			&i.Chirp.Body,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.SearchVector,
+			&i.Chirp.InReplyTo,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpOf,
+			&i.Chirp.QuoteOf,
+			pq.Array(&i.Terms),
+			&i.FlaggedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listModerationTerms = `-- name: ListModerationTerms :many
+SELECT term, action, created_at FROM moderation_terms ORDER BY term
+`
+
+func (q *Queries) ListModerationTerms(ctx context.Context) ([]ModerationTerm, error) {
+	rows, err := q.db.QueryContext(ctx, listModerationTerms)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ModerationTerm
+	for rows.Next() {
+		var i ModerationTerm
+		if err := rows.Scan(&i.Term, &i.Action, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const unflagChirp = `-- name: UnflagChirp :exec
+DELETE FROM chirp_flags WHERE chirp_id = $1
+`
+
+func (q *Queries) UnflagChirp(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unflagChirp, chirpID)
+	return err
+}
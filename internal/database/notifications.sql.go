@@ -0,0 +1,144 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: notifications.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countUnreadNotifications = `-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL
+`
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotifications, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createNotification = `-- name: CreateNotification :exec
+INSERT INTO notifications (id, user_id, actor_id, type, chirp_id)
+SELECT gen_random_uuid(), $1::uuid, $2::uuid, $3::text, $4::uuid
+WHERE NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = $2::uuid AND users.shadow_banned_at IS NOT NULL)
+  AND NOT EXISTS (
+    SELECT 1 FROM notifications
+    WHERE notifications.user_id = $1::uuid
+      AND notifications.actor_id = $2::uuid
+      AND notifications.type = $3::text
+      AND notifications.chirp_id IS NOT DISTINCT FROM $4::uuid
+      AND (notifications.read_at IS NULL OR notifications.created_at > NOW() - INTERVAL '1 day'))
+`
+
+type CreateNotificationParams struct {
+	UserID  uuid.UUID     `json:"user_id"`
+	ActorID uuid.UUID     `json:"actor_id"`
+	Type    string        `json:"type"`
+	ChirpID uuid.NullUUID `json:"chirp_id"`
+}
+
+// Shadow-banned users don't know they are, so their actions still succeed
+// but nobody is told about them. Undoing and redoing an action, such as
+// following again after an unfollow, doesn't repeat a notification that is
+// unread or less than a day old.
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) error {
+	_, err := q.db.ExecContext(ctx, createNotification,
+		arg.UserID,
+		arg.ActorID,
+		arg.Type,
+		arg.ChirpID,
+	)
+	return err
+}
+
+const getNotifications = `-- name: GetNotifications :many
+SELECT id, user_id, actor_id, type, chirp_id, created_at, read_at FROM notifications
+WHERE user_id = $1
+  AND (NOT $2::boolean OR read_at IS NULL)
+  AND ($3::timestamp IS NULL
+   OR (created_at, id) < ($3::timestamp, $4::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $5
+`
+
+type GetNotificationsParams struct {
+	UserID          uuid.UUID     `json:"user_id"`
+	UnreadOnly      bool          `json:"unread_only"`
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetNotifications(ctx context.Context, arg GetNotificationsParams) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, getNotifications,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ActorID,
+			&i.Type,
+			&i.ChirpID,
+			&i.CreatedAt,
+			&i.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAllNotificationsRead = `-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL
+`
+
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markAllNotificationsRead, userID)
+	return err
+}
+
+const markNotificationsRead = `-- name: MarkNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+  AND read_at IS NULL
+  AND id = ANY($2::uuid[])
+`
+
+type MarkNotificationsReadParams struct {
+	UserID uuid.UUID   `json:"user_id"`
+	Ids    []uuid.UUID `json:"ids"`
+}
+
+func (q *Queries) MarkNotificationsRead(ctx context.Context, arg MarkNotificationsReadParams) error {
+	_, err := q.db.ExecContext(ctx, markNotificationsRead, arg.UserID, pq.Array(arg.Ids))
+	return err
+}
@@ -0,0 +1,201 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: reports.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (id, chirp_id, chirp_author_id, chirp_body, reporter_id, reason, details)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING id, chirp_id, chirp_author_id, chirp_body, reporter_id, reason, details, created_at, status, resolution, resolution_note, resolved_by, resolved_at
+`
+
+type CreateReportParams struct {
+	ChirpID       uuid.NullUUID `json:"chirp_id"`
+	ChirpAuthorID uuid.UUID     `json:"chirp_author_id"`
+	ChirpBody     string        `json:"chirp_body"`
+	ReporterID    uuid.UUID     `json:"reporter_id"`
+	Reason        string        `json:"reason"`
+	Details       string        `json:"details"`
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.ChirpID,
+		arg.ChirpAuthorID,
+		arg.ChirpBody,
+		arg.ReporterID,
+		arg.Reason,
+		arg.Details,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ChirpAuthorID,
+		&i.ChirpBody,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Details,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Resolution,
+		&i.ResolutionNote,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const getOpenReports = `-- name: GetOpenReports :many
+SELECT id, chirp_id, chirp_author_id, chirp_body, reporter_id, reason, details, created_at, status, resolution, resolution_note, resolved_by, resolved_at FROM reports
+WHERE status = 'open'
+  AND ($1::timestamp IS NULL
+   OR (created_at, id) > ($1::timestamp, $2::uuid))
+ORDER BY created_at, id
+LIMIT $3
+`
+
+type GetOpenReportsParams struct {
+	CursorCreatedAt sql.NullTime  `json:"cursor_created_at"`
+	CursorID        uuid.NullUUID `json:"cursor_id"`
+	Limit           int32         `json:"limit"`
+}
+
+func (q *Queries) GetOpenReports(ctx context.Context, arg GetOpenReportsParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, getOpenReports, arg.CursorCreatedAt, arg.CursorID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.ChirpAuthorID,
+			&i.ChirpBody,
+			&i.ReporterID,
+			&i.Reason,
+			&i.Details,
+			&i.CreatedAt,
+			&i.Status,
+			&i.Resolution,
+			&i.ResolutionNote,
+			&i.ResolvedBy,
+			&i.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getReportForUpdate = `-- name: GetReportForUpdate :one
+SELECT id, chirp_id, chirp_author_id, chirp_body, reporter_id, reason, details, created_at, status, resolution, resolution_note, resolved_by, resolved_at FROM reports WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetReportForUpdate(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, getReportForUpdate, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ChirpAuthorID,
+		&i.ChirpBody,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Details,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Resolution,
+		&i.ResolutionNote,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
+
+const resolveChirpReports = `-- name: ResolveChirpReports :exec
+UPDATE reports
+SET status = $2, resolution = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW()
+WHERE chirp_id = $1 AND status = 'open'
+`
+
+type ResolveChirpReportsParams struct {
+	ChirpID        uuid.NullUUID `json:"chirp_id"`
+	Status         string        `json:"status"`
+	Resolution     *string       `json:"resolution"`
+	ResolutionNote string        `json:"resolution_note"`
+	ResolvedBy     uuid.NullUUID `json:"resolved_by"`
+}
+
+// Resolves the other open reports about a chirp along with the one acted on.
+func (q *Queries) ResolveChirpReports(ctx context.Context, arg ResolveChirpReportsParams) error {
+	_, err := q.db.ExecContext(ctx, resolveChirpReports,
+		arg.ChirpID,
+		arg.Status,
+		arg.Resolution,
+		arg.ResolutionNote,
+		arg.ResolvedBy,
+	)
+	return err
+}
+
+const resolveReport = `-- name: ResolveReport :one
+UPDATE reports
+SET status = $2, resolution = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW()
+WHERE id = $1 RETURNING id, chirp_id, chirp_author_id, chirp_body, reporter_id, reason, details, created_at, status, resolution, resolution_note, resolved_by, resolved_at
+`
+
+type ResolveReportParams struct {
+	ID             uuid.UUID     `json:"id"`
+	Status         string        `json:"status"`
+	Resolution     *string       `json:"resolution"`
+	ResolutionNote string        `json:"resolution_note"`
+	ResolvedBy     uuid.NullUUID `json:"resolved_by"`
+}
+
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, resolveReport,
+		arg.ID,
+		arg.Status,
+		arg.Resolution,
+		arg.ResolutionNote,
+		arg.ResolvedBy,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.ChirpAuthorID,
+		&i.ChirpBody,
+		&i.ReporterID,
+		&i.Reason,
+		&i.Details,
+		&i.CreatedAt,
+		&i.Status,
+		&i.Resolution,
+		&i.ResolutionNote,
+		&i.ResolvedBy,
+		&i.ResolvedAt,
+	)
+	return i, err
+}
@@ -7,14 +7,50 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const bootstrapAdmin = `-- name: BootstrapAdmin :one
+UPDATE users
+SET role = 'admin', updated_at = NOW()
+WHERE users.email = $1 AND NOT EXISTS (SELECT 1 FROM users AS admins WHERE admins.role = 'admin')
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
+`
+
+// Promotes a user to admin, but only while there are no admins at all.
+func (q *Queries) BootstrapAdmin(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, bootstrapAdmin, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
 const changeChirpyRedStatus = `-- name: ChangeChirpyRedStatus :one
 UPDATE users
 SET is_chirpy_red = $2, updated_at = NOW()
-WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
 `
 
 type ChangeChirpyRedStatusParams struct {
@@ -32,6 +68,53 @@ func (q *Queries) ChangeChirpyRedStatus(ctx context.Context, arg ChangeChirpyRed
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const confirmEmail = `-- name: ConfirmEmail :one
+UPDATE users
+SET email = $2, email_verified_at = NOW(), pending_email = NULL, updated_at = NOW()
+WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
+`
+
+type ConfirmEmailParams struct {
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
+}
+
+func (q *Queries) ConfirmEmail(ctx context.Context, arg ConfirmEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
@@ -39,7 +122,7 @@ func (q *Queries) ChangeChirpyRedStatus(ctx context.Context, arg ChangeChirpyRed
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, email, hashed_password)
 VALUES (gen_random_uuid(), $1, $2)
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
 `
 
 type CreateUserParams struct {
@@ -57,6 +140,17 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
@@ -70,8 +164,35 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const disableTOTP = `-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableTOTP, id)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type EnableTOTPParams struct {
+	ID           uuid.UUID `json:"id"`
+	TotpLastStep int64     `json:"-"`
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableTOTP, arg.ID, arg.TotpLastStep)
+	return err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -84,24 +205,257 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
 
-const updateCredentials = `-- name: UpdateCredentials :one
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at FROM users WHERE lower(handle) = lower($1)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at FROM users WHERE lower(handle) = ANY($1::text[])
+`
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.Handle,
+			&i.DisplayName,
+			&i.Bio,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastStep,
+			&i.EmailVerifiedAt,
+			&i.PendingEmail,
+			&i.Role,
+			&i.SuspendedUntil,
+			&i.ShadowBannedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockAdmins = `-- name: LockAdmins :many
+SELECT id FROM users WHERE role = 'admin' FOR UPDATE
+`
+
+// Locks every admin so that concurrent demotions can't remove the last one.
+func (q *Queries) LockAdmins(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, lockAdmins)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setAccountState = `-- name: SetAccountState :one
+UPDATE users
+SET suspended_until = $1,
+    shadow_banned_at = $2,
+    updated_at = NOW()
+WHERE id = $3 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
+`
+
+type SetAccountStateParams struct {
+	SuspendedUntil *time.Time `json:"suspended_until"`
+	ShadowBannedAt *time.Time `json:"-"`
+	ID             uuid.UUID  `json:"id"`
+}
+
+func (q *Queries) SetAccountState(ctx context.Context, arg SetAccountStateParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setAccountState, arg.SuspendedUntil, arg.ShadowBannedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_last_step = 0, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL
+`
+
+type SetTOTPSecretParams struct {
+	ID         uuid.UUID      `json:"id"`
+	TotpSecret sql.NullString `json:"-"`
+}
+
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
-WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET suspended_until = GREATEST(suspended_until, $1::timestamp), updated_at = NOW()
+WHERE id = $2 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
 `
 
-type UpdateCredentialsParams struct {
+type SuspendUserParams struct {
+	SuspendedUntil time.Time `json:"suspended_until"`
 	ID             uuid.UUID `json:"id"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"-"`
 }
 
-func (q *Queries) UpdateCredentials(ctx context.Context, arg UpdateCredentialsParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateCredentials, arg.ID, arg.Email, arg.HashedPassword)
+// Never shortens a suspension that already lasts longer.
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.SuspendedUntil, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -110,6 +464,88 @@ func (q *Queries) UpdateCredentials(ctx context.Context, arg UpdateCredentialsPa
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
 	)
 	return i, err
 }
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET hashed_password = COALESCE($1, hashed_password),
+    handle = COALESCE($2, handle),
+    display_name = COALESCE($3, display_name),
+    bio = COALESCE($4, bio),
+    pending_email = COALESCE($5, pending_email),
+    updated_at = NOW()
+WHERE id = $6 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, totp_secret, totp_enabled_at, totp_last_step, email_verified_at, pending_email, role, suspended_until, shadow_banned_at
+`
+
+type UpdateUserParams struct {
+	HashedPassword sql.NullString `json:"-"`
+	Handle         *string        `json:"handle"`
+	DisplayName    sql.NullString `json:"display_name"`
+	Bio            sql.NullString `json:"bio"`
+	PendingEmail   *string        `json:"pending_email"`
+	ID             uuid.UUID      `json:"id"`
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.HashedPassword,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.PendingEmail,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBannedAt,
+	)
+	return i, err
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_last_step < $2
+`
+
+type UseTOTPStepParams struct {
+	ID           uuid.UUID `json:"id"`
+	TotpLastStep int64     `json:"-"`
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,29 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhook_events.sql
+
+package database
+
+import (
+	"context"
+)
+
+const recordWebhookEvent = `-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (id, event)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type RecordWebhookEventParams struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+}
+
+func (q *Queries) RecordWebhookEvent(ctx context.Context, arg RecordWebhookEventParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recordWebhookEvent, arg.ID, arg.Event)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
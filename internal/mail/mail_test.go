@@ -0,0 +1,147 @@
+package mail_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/mail"
+)
+
+// smtpStandIn accepts a single SMTP session and hands back the envelope and
+// data it received.
+type smtpStandIn struct {
+	ln   net.Listener
+	from string
+	to   []string
+	data string
+	done chan error
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	s := &smtpStandIn{ln: ln, done: make(chan error, 1)}
+	go func() { s.done <- s.serve() }()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpStandIn) serve() error {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return err
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			tp.PrintfLine("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			tp.PrintfLine("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.to = append(s.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			tp.PrintfLine("250 OK")
+		case cmd == "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return err
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			tp.PrintfLine("221 Bye")
+			return nil
+		default:
+			tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newSMTPStandIn(t)
+	mailer := &mail.SMTPMailer{Addr: server.ln.Addr().String(), From: "chirpy@example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := mailer.Send(ctx, mail.Message{
+		To:      "walt@example.com",
+		Subject: "Reset your password",
+		Body:    "Follow this link:\n.https://example.com/reset\n",
+	})
+	if err != nil {
+		t.Fatalf("Error sending mail: %v", err)
+	}
+	if err := <-server.done; err != nil {
+		t.Fatalf("SMTP stand-in failed: %v", err)
+	}
+	if server.from != "chirpy@example.com" {
+		t.Errorf("Unexpected sender: %q", server.from)
+	}
+	if len(server.to) != 1 || server.to[0] != "walt@example.com" {
+		t.Errorf("Unexpected recipients: %q", server.to)
+	}
+	for _, want := range []string{"To: walt@example.com\n", "Subject: Reset your password\n", "\n.https://example.com/reset\n"} {
+		if !strings.Contains(server.data, want) {
+			t.Errorf("Expected message to contain %q, got:\n%s", want, server.data)
+		}
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := mail.NewLogMailer("chirpy@example.com", &buf)
+	err := mailer.Send(context.Background(), mail.Message{
+		To:      "walt@example.com",
+		Subject: "Hello",
+		Body:    "Line one\nLine two",
+	})
+	if err != nil {
+		t.Fatalf("Error sending mail: %v", err)
+	}
+	br := bufio.NewReader(&buf)
+	header, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("Error reading message header: %v", err)
+	}
+	if header.Get("From") != "chirpy@example.com" || header.Get("To") != "walt@example.com" || header.Get("Subject") != "Hello" {
+		t.Errorf("Unexpected header: %v", header)
+	}
+	body, err := io.ReadAll(br)
+	if err != nil {
+		t.Fatalf("Error reading message body: %v", err)
+	}
+	if string(body) != "Line one\r\nLine two\r\n\r\n" {
+		t.Errorf("Unexpected body: %q", body)
+	}
+}
+
+func TestRejectsHeaderInjection(t *testing.T) {
+	mailer := mail.NewLogMailer("chirpy@example.com", &bytes.Buffer{})
+	err := mailer.Send(context.Background(), mail.Message{
+		To:      "walt@example.com\r\nBcc: everyone@example.com",
+		Subject: "Hello",
+	})
+	if !errors.Is(err, mail.ErrInvalidHeader) {
+		t.Errorf("Expected %v, got %v", mail.ErrInvalidHeader, err)
+	}
+}
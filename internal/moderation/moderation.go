@@ -0,0 +1,219 @@
+// Package moderation checks chirps against a list of banned terms. Terms
+// match whole words regardless of case, surrounding punctuation and common
+// leetspeak, so "F0RN@X!" matches the term "fornax".
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Action is what happens to a chirp that contains a term.
+type Action string
+
+const (
+	// ActionMask replaces the term with asterisks.
+	ActionMask Action = "mask"
+	// ActionFlag keeps the chirp as written but marks it for review.
+	ActionFlag Action = "flag"
+	// ActionReject refuses the chirp.
+	ActionReject Action = "reject"
+)
+
+// severity orders actions so that when two terms normalize to the same word
+// the stricter action wins.
+var severity = map[Action]int{
+	ActionMask:   1,
+	ActionFlag:   2,
+	ActionReject: 3,
+}
+
+func (a Action) Valid() bool {
+	_, ok := severity[a]
+	return ok
+}
+
+type Term struct {
+	Word   string
+	Action Action
+}
+
+// DefaultTerms is the list used when nothing else is configured.
+var DefaultTerms = []Term{
+	{Word: "kerfuffle", Action: ActionMask},
+	{Word: "sharbert", Action: ActionMask},
+	{Word: "fornax", Action: ActionMask},
+}
+
+const mask = "****"
+
+// Result is the outcome of checking a chirp.
+type Result struct {
+	// Body is the chirp with every masked term replaced by "****".
+	Body string
+	// Rejected is true if the chirp contains a term with ActionReject.
+	Rejected bool
+	// Flagged lists the terms with ActionFlag the chirp contains, in the
+	// order they first appear.
+	Flagged []string
+}
+
+// Filter is a compiled term list. It is safe for concurrent use.
+type Filter struct {
+	terms map[string]Term
+}
+
+// NewFilter compiles terms. A term must be a single word; it may contain
+// leetspeak symbols such as '@' but no spaces or other punctuation.
+func NewFilter(terms []Term) (*Filter, error) {
+	f := &Filter{terms: make(map[string]Term, len(terms))}
+	for _, term := range terms {
+		if !term.Action.Valid() {
+			return nil, fmt.Errorf("term %q: unknown action %q", term.Word, term.Action)
+		}
+		if term.Word == "" || strings.IndexFunc(term.Word, func(r rune) bool { return !isWordRune(r) }) >= 0 {
+			return nil, fmt.Errorf("term %q: must be a single word", term.Word)
+		}
+		key := normalize(term.Word)
+		if prev, ok := f.terms[key]; ok && severity[prev.Action] >= severity[term.Action] {
+			continue
+		}
+		f.terms[key] = term
+	}
+	return f, nil
+}
+
+// Len returns the number of distinct terms in the filter.
+func (f *Filter) Len() int {
+	return len(f.terms)
+}
+
+// Check looks for terms in body. Everything but the masked words, including
+// whitespace, is left exactly as it was.
+func (f *Filter) Check(body string) Result {
+	var res Result
+	var out strings.Builder
+	seen := make(map[string]bool)
+	last := 0
+	for start := 0; start < len(body); {
+		r, size := utf8.DecodeRuneInString(body[start:])
+		if !isWordRune(r) {
+			start += size
+			continue
+		}
+		end := start + size
+		for end < len(body) {
+			r, size := utf8.DecodeRuneInString(body[end:])
+			if !isWordRune(r) {
+				break
+			}
+			end += size
+		}
+		if from, to, term, ok := f.match(body[start:end]); ok {
+			switch term.Action {
+			case ActionMask:
+				out.WriteString(body[last : start+from])
+				out.WriteString(mask)
+				last = start + to
+			case ActionFlag:
+				if !seen[term.Word] {
+					seen[term.Word] = true
+					res.Flagged = append(res.Flagged, term.Word)
+				}
+			case ActionReject:
+				res.Rejected = true
+			}
+		}
+		start = end
+	}
+	out.WriteString(body[last:])
+	res.Body = out.String()
+	return res
+}
+
+// match looks a word up as a whole, then without the symbols at either end,
+// so "fornax!" matches "fornax" while "$harbert" still matches "sharbert".
+// It returns the byte range of word that matched.
+func (f *Filter) match(word string) (int, int, Term, bool) {
+	if term, ok := f.terms[normalize(word)]; ok {
+		return 0, len(word), term, true
+	}
+	trimmed := strings.TrimFunc(word, isSymbol)
+	if trimmed == "" || trimmed == word {
+		return 0, 0, Term{}, false
+	}
+	if term, ok := f.terms[normalize(trimmed)]; ok {
+		from := strings.Index(word, trimmed)
+		return from, from + len(trimmed), term, true
+	}
+	return 0, 0, Term{}, false
+}
+
+// leet maps characters commonly substituted for letters to the letter they
+// stand for. Letters that are easily confused, such as 'l' and 'i', map to
+// the same rune, which is applied to terms and chirps alike.
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'!': 'i',
+	'|': 'i',
+	'l': 'i',
+	'3': 'e',
+	'4': 'a',
+	'@': 'a',
+	'5': 's',
+	'$': 's',
+	'7': 't',
+	'+': 't',
+	'8': 'b',
+	'9': 'g',
+}
+
+// narrow maps fullwidth forms such as 'Ｆ' to their ASCII equivalents.
+func narrow(r rune) rune {
+	if r >= '！' && r <= '～' {
+		return r - '！' + '!'
+	}
+	return r
+}
+
+func isSymbol(r rune) bool {
+	r = narrow(r)
+	_, ok := leet[r]
+	return ok && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || isSymbol(r)
+}
+
+// normalize case folds and narrows word, undoes leetspeak and drops
+// combining marks.
+func normalize(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(foldRune(narrow(r)))
+		if l, ok := leet[r]; ok {
+			r = l
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// foldRune returns the smallest rune in r's case folding orbit, so that for
+// example 'K', 'k' and the Kelvin sign all fold to 'K'.
+func foldRune(r rune) rune {
+	smallest := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < smallest {
+			smallest = f
+		}
+	}
+	return smallest
+}
@@ -0,0 +1,160 @@
+package moderation_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aleksaelezovic/chirpy/internal/moderation"
+)
+
+func mustFilter(t *testing.T, terms []moderation.Term) *moderation.Filter {
+	t.Helper()
+	f, err := moderation.NewFilter(terms)
+	if err != nil {
+		t.Fatalf("Error creating filter: %v", err)
+	}
+	return f
+}
+
+func TestMask(t *testing.T) {
+	f := mustFilter(t, moderation.DefaultTerms)
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"I had something interesting for breakfast", "I had something interesting for breakfast"},
+		{"I hear Mastodon is better than Chirpy. sharbert I need to migrate", "I hear Mastodon is better than Chirpy. **** I need to migrate"},
+		{"I really need a kerfuffle to go to bed sooner, Fornax !", "I really need a **** to go to bed sooner, **** !"},
+		{"fornax! Kerfuffle, (sharbert)", "****! ****, (****)"},
+		{"F0RN@X and $harbert and k3rfuffl3", "**** and **** and ****"},
+		{"tabs\tand  double  spaces fornax", "tabs\tand  double  spaces ****"},
+		{"kerfuffle's", "****'s"},
+		{"fornaxes and sharbertine", "fornaxes and sharbertine"},
+		{"ＦＯＲＮＡＸ", "****"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			got := f.Check(tt.body)
+			if got.Body != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got.Body)
+			}
+			if got.Rejected || len(got.Flagged) > 0 {
+				t.Errorf("Expected only masking, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestActions(t *testing.T) {
+	f := mustFilter(t, []moderation.Term{
+		{Word: "fornax", Action: moderation.ActionMask},
+		{Word: "kerfuffle", Action: moderation.ActionFlag},
+		{Word: "sharbert", Action: moderation.ActionReject},
+	})
+
+	got := f.Check("Kerfuffle! fornax kerfuffle")
+	if got.Body != "Kerfuffle! **** kerfuffle" {
+		t.Errorf("Expected flagged terms to be kept, got %q", got.Body)
+	}
+	if !slices.Equal(got.Flagged, []string{"kerfuffle"}) {
+		t.Errorf("Expected kerfuffle to be flagged once, got %v", got.Flagged)
+	}
+	if got.Rejected {
+		t.Errorf("Expected chirp not to be rejected")
+	}
+
+	if got := f.Check("what a $harbert"); !got.Rejected {
+		t.Errorf("Expected chirp to be rejected")
+	}
+}
+
+func TestStricterActionWins(t *testing.T) {
+	f := mustFilter(t, []moderation.Term{
+		{Word: "fornax", Action: moderation.ActionReject},
+		{Word: "F0rnax", Action: moderation.ActionMask},
+	})
+	if f.Len() != 1 {
+		t.Errorf("Expected 1 term, got %d", f.Len())
+	}
+	if got := f.Check("fornax"); !got.Rejected {
+		t.Errorf("Expected chirp to be rejected")
+	}
+}
+
+func TestNewFilterRejectsInvalidTerms(t *testing.T) {
+	for _, term := range []moderation.Term{
+		{Word: "two words", Action: moderation.ActionMask},
+		{Word: "", Action: moderation.ActionMask},
+		{Word: "fornax", Action: "delete"},
+	} {
+		if _, err := moderation.NewFilter([]moderation.Term{term}); err == nil {
+			t.Errorf("Expected %+v to be rejected", term)
+		}
+	}
+}
+
+func TestParseTerms(t *testing.T) {
+	terms, err := moderation.ParseTerms(strings.NewReader(`# banned terms
+fornax
+
+kerfuffle reject
+  sharbert   flag
+`))
+	if err != nil {
+		t.Fatalf("Error parsing terms: %v", err)
+	}
+	want := []moderation.Term{
+		{Word: "fornax", Action: moderation.ActionMask},
+		{Word: "kerfuffle", Action: moderation.ActionReject},
+		{Word: "sharbert", Action: moderation.ActionFlag},
+	}
+	if !slices.Equal(terms, want) {
+		t.Errorf("Expected %v, got %v", want, terms)
+	}
+
+	for _, input := range []string{"fornax delete\n", "fornax mask now\n"} {
+		if _, err := moderation.ParseTerms(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected %q to fail", input)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terms.txt")
+	if err := os.WriteFile(path, []byte("fornax\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := moderation.NewModerator(context.Background(), moderation.FileSource{Path: path})
+	if err != nil {
+		t.Fatalf("Error creating moderator: %v", err)
+	}
+	if got := m.Check("fornax sharbert").Body; got != "**** sharbert" {
+		t.Errorf("Expected %q, got %q", "**** sharbert", got)
+	}
+
+	if err := os.WriteFile(path, []byte("sharbert\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reload(context.Background()); err != nil {
+		t.Fatalf("Error reloading: %v", err)
+	}
+	if got := m.Check("fornax sharbert").Body; got != "fornax ****" {
+		t.Errorf("Expected %q, got %q", "fornax ****", got)
+	}
+
+	// A broken list is refused and the previous one stays in use.
+	if err := os.WriteFile(path, []byte("sharbert delete\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reload(context.Background()); err == nil {
+		t.Errorf("Expected reload of an invalid list to fail")
+	}
+	if got := m.Check("fornax sharbert").Body; got != "fornax ****" {
+		t.Errorf("Expected %q, got %q", "fornax ****", got)
+	}
+}
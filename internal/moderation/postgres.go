@@ -0,0 +1,29 @@
+package moderation
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+// PostgresSource reads terms from the moderation_terms table.
+type PostgresSource struct {
+	db *sql.DB
+}
+
+func NewPostgresSource(db *sql.DB) *PostgresSource {
+	return &PostgresSource{db: db}
+}
+
+func (p *PostgresSource) Load(ctx context.Context) ([]Term, error) {
+	rows, err := database.New(p.db).ListModerationTerms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms := make([]Term, len(rows))
+	for i, row := range rows {
+		terms[i] = Term{Word: row.Term, Action: Action(row.Action)}
+	}
+	return terms, nil
+}
@@ -0,0 +1,132 @@
+package moderation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Source provides the current term list.
+type Source interface {
+	Load(ctx context.Context) ([]Term, error)
+}
+
+// StaticSource always returns the same terms.
+type StaticSource []Term
+
+func (s StaticSource) Load(ctx context.Context) ([]Term, error) {
+	return s, nil
+}
+
+// FileSource reads terms from a text file in the format of ParseTerms.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Load(ctx context.Context) ([]Term, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	terms, err := ParseTerms(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	return terms, nil
+}
+
+// ParseTerms reads one term per line, optionally followed by its action:
+//
+//	# comments and blank lines are ignored
+//	fornax
+//	kerfuffle reject
+//	sharbert flag
+//
+// Terms without an action are masked.
+func ParseTerms(r io.Reader) ([]Term, error) {
+	var terms []Term
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		term := Term{Word: fields[0], Action: ActionMask}
+		switch len(fields) {
+		case 1:
+		case 2:
+			term.Action = Action(fields[1])
+		default:
+			return nil, fmt.Errorf("line %d: expected a term and an optional action", line)
+		}
+		if !term.Action.Valid() {
+			return nil, fmt.Errorf("line %d: unknown action %q", line, term.Action)
+		}
+		terms = append(terms, term)
+	}
+	return terms, scanner.Err()
+}
+
+// Moderator checks chirps against the latest term list loaded from its
+// source. Reloading swaps the list atomically, so checks never wait for it.
+type Moderator struct {
+	source Source
+	filter atomic.Pointer[Filter]
+}
+
+// NewModerator loads the initial term list, failing if the source can't
+// provide one.
+func NewModerator(ctx context.Context, source Source) (*Moderator, error) {
+	m := &Moderator{source: source}
+	if err := m.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Moderator) Check(body string) Result {
+	return m.filter.Load().Check(body)
+}
+
+// Len returns the number of terms in the current list.
+func (m *Moderator) Len() int {
+	return m.filter.Load().Len()
+}
+
+// Reload replaces the term list with a fresh one from the source. If the
+// source fails or returns an invalid list, the current list stays in use.
+func (m *Moderator) Reload(ctx context.Context) error {
+	terms, err := m.source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	filter, err := NewFilter(terms)
+	if err != nil {
+		return err
+	}
+	m.filter.Store(filter)
+	return nil
+}
+
+// Watch reloads the term list every interval until ctx is done. Failed
+// reloads are passed to onError.
+func (m *Moderator) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Reload(ctx); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepEvery = 1024
+
+// MemoryStore keeps State in memory. It is the right choice for a single
+// instance; run several and each one counts failures on its own.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+	ttl    time.Duration
+	writes int
+}
+
+// NewMemoryStore returns a store that forgets keys which have neither failed
+// nor been locked within ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{states: make(map[string]State), ttl: ttl}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states[key], nil
+}
+
+func (m *MemoryStore) Update(ctx context.Context, key string, fn func(State) State) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := fn(m.states[key])
+	m.states[key] = s
+	m.writes++
+	if m.writes%sweepEvery == 0 {
+		m.sweep(time.Now())
+	}
+	return s, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, key)
+	return nil
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, s := range m.states {
+		if now.Sub(s.LastFailure) > m.ttl && now.After(s.LockedUntil) {
+			delete(m.states, key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/database"
+)
+
+// PostgresStore keeps State in the login_attempts table, so that every
+// instance behind a load balancer sees the same failures.
+type PostgresStore struct {
+	db     *sql.DB
+	ttl    time.Duration
+	writes atomic.Int64
+}
+
+// NewPostgresStore returns a store that deletes rows which have neither
+// failed nor been locked within ttl.
+func NewPostgresStore(db *sql.DB, ttl time.Duration) *PostgresStore {
+	return &PostgresStore{db: db, ttl: ttl}
+}
+
+// Times are stored in UTC because the columns have no time zone.
+
+func toState(row database.LoginAttempt) State {
+	return State{
+		Failures:    int(row.Failures),
+		LastFailure: row.LastFailureAt.Time,
+		LockedUntil: row.LockedUntil.Time,
+	}
+}
+
+func toNullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func (p *PostgresStore) Get(ctx context.Context, key string) (State, error) {
+	row, err := database.New(p.db).GetLoginAttempt(ctx, key)
+	if err == sql.ErrNoRows {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	return toState(row), nil
+}
+
+func (p *PostgresStore) Update(ctx context.Context, key string, fn func(State) State) (State, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return State{}, err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+
+	if err := q.EnsureLoginAttempt(ctx, key); err != nil {
+		return State{}, err
+	}
+	row, err := q.GetLoginAttemptForUpdate(ctx, key)
+	if err != nil {
+		return State{}, err
+	}
+	s := fn(toState(row))
+	err = q.UpdateLoginAttempt(ctx, database.UpdateLoginAttemptParams{
+		Key:           key,
+		Failures:      int32(s.Failures),
+		LastFailureAt: toNullTime(s.LastFailure),
+		LockedUntil:   toNullTime(s.LockedUntil),
+	})
+	if err != nil {
+		return State{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return State{}, err
+	}
+	if p.writes.Add(1)%sweepEvery == 0 {
+		p.sweep(ctx, time.Now())
+	}
+	return s, nil
+}
+
+// sweep only logs its errors: the write it follows has already succeeded.
+func (p *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	err := database.New(p.db).DeleteExpiredLoginAttempts(ctx, database.DeleteExpiredLoginAttemptsParams{
+		FailedBefore: now.Add(-p.ttl).UTC(),
+		Now:          now.UTC(),
+	})
+	if err != nil {
+		log.Printf("throttle: deleting expired login attempts: %v", err)
+	}
+}
+
+func (p *PostgresStore) Delete(ctx context.Context, key string) error {
+	return database.New(p.db).DeleteLoginAttempt(ctx, key)
+}
@@ -0,0 +1,113 @@
+// Package throttle slows down and locks out clients that keep failing, such
+// as someone guessing passwords.
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Policy describes how failures are punished. After FreeAttempts failures
+// every further attempt has to wait BaseDelay, doubling with each failure up
+// to MaxDelay. LockoutAfter failures lock the key for LockoutFor. Failures
+// are forgotten once Window has passed without a new one.
+type Policy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockoutAfter int
+	LockoutFor   time.Duration
+	Window       time.Duration
+}
+
+// State is what a Store keeps for each key.
+type State struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store persists State. Update must apply fn atomically, so that concurrent
+// failures for the same key are all counted.
+type Store interface {
+	Get(ctx context.Context, key string) (State, error)
+	Update(ctx context.Context, key string, fn func(State) State) (State, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+// current drops failures that have aged out or that led to a lockout which
+// has since ended, so the key starts over with its free attempts.
+func (l *Limiter) current(s State, now time.Time) State {
+	if !s.LockedUntil.IsZero() && !now.Before(s.LockedUntil) {
+		return State{}
+	}
+	if s.LockedUntil.IsZero() && now.Sub(s.LastFailure) > l.policy.Window {
+		return State{}
+	}
+	return s
+}
+
+func (l *Limiter) retryAfter(s State, now time.Time) time.Duration {
+	s = l.current(s, now)
+	if now.Before(s.LockedUntil) {
+		return s.LockedUntil.Sub(now)
+	}
+	if s.Failures < l.policy.FreeAttempts {
+		return 0
+	}
+	delay := l.policy.BaseDelay
+	for i := l.policy.FreeAttempts; i < s.Failures && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, l.policy.MaxDelay)
+	if wait := s.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// RetryAfter returns how long key has to wait before its next attempt, or 0
+// if it may try now.
+func (l *Limiter) RetryAfter(ctx context.Context, key string) (time.Duration, error) {
+	s, err := l.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return l.retryAfter(s, l.now()), nil
+}
+
+// Fail records a failed attempt for key. locked reports whether this failure
+// is the one that locked the key out.
+func (l *Limiter) Fail(ctx context.Context, key string) (s State, locked bool, err error) {
+	now := l.now()
+	s, err = l.store.Update(ctx, key, func(s State) State {
+		s = l.current(s, now)
+		locked = false
+		if now.Before(s.LockedUntil) {
+			return s
+		}
+		s.Failures++
+		s.LastFailure = now
+		if s.Failures >= l.policy.LockoutAfter {
+			s.LockedUntil = now.Add(l.policy.LockoutFor)
+			locked = true
+		}
+		return s
+	})
+	return s, locked, err
+}
+
+// Succeed forgets the failures recorded for key.
+func (l *Limiter) Succeed(ctx context.Context, key string) error {
+	return l.store.Delete(ctx, key)
+}
@@ -0,0 +1,152 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     8 * time.Second,
+	LockoutAfter: 8,
+	LockoutFor:   15 * time.Minute,
+	Window:       time.Hour,
+}
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter() (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)}
+	l := NewLimiter(NewMemoryStore(time.Hour), testPolicy)
+	l.now = clock.now
+	return l, clock
+}
+
+func mustRetryAfter(t *testing.T, l *Limiter, key string) time.Duration {
+	t.Helper()
+	wait, err := l.RetryAfter(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Error checking key: %v", err)
+	}
+	return wait
+}
+
+func mustFail(t *testing.T, l *Limiter, key string) bool {
+	t.Helper()
+	_, locked, err := l.Fail(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Error recording failure: %v", err)
+	}
+	return locked
+}
+
+func TestBackoff(t *testing.T) {
+	l, clock := newTestLimiter()
+	for range testPolicy.FreeAttempts - 1 {
+		mustFail(t, l, "k")
+		if wait := mustRetryAfter(t, l, "k"); wait != 0 {
+			t.Fatalf("Expected free attempt, got wait %v", wait)
+		}
+	}
+	for _, want := range []time.Duration{1, 2, 4, 8, 8} {
+		mustFail(t, l, "k")
+		if wait := mustRetryAfter(t, l, "k"); wait != want*time.Second {
+			t.Errorf("Expected wait %v, got %v", want*time.Second, wait)
+		}
+		clock.advance(want * time.Second)
+		if wait := mustRetryAfter(t, l, "k"); wait != 0 {
+			t.Errorf("Expected no wait after backing off, got %v", wait)
+		}
+	}
+	if wait := mustRetryAfter(t, l, "other"); wait != 0 {
+		t.Errorf("Expected other keys to be unaffected, got %v", wait)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	l, clock := newTestLimiter()
+	for i := 1; i < testPolicy.LockoutAfter; i++ {
+		if mustFail(t, l, "k") {
+			t.Fatalf("Unexpected lockout after %d failures", i)
+		}
+	}
+	if !mustFail(t, l, "k") {
+		t.Fatalf("Expected lockout after %d failures", testPolicy.LockoutAfter)
+	}
+	if wait := mustRetryAfter(t, l, "k"); wait != testPolicy.LockoutFor {
+		t.Errorf("Expected wait %v, got %v", testPolicy.LockoutFor, wait)
+	}
+	// Failures while locked neither extend nor repeat the lockout.
+	clock.advance(time.Minute)
+	if mustFail(t, l, "k") {
+		t.Errorf("Expected lockout to be reported once")
+	}
+	if wait := mustRetryAfter(t, l, "k"); wait != testPolicy.LockoutFor-time.Minute {
+		t.Errorf("Expected wait %v, got %v", testPolicy.LockoutFor-time.Minute, wait)
+	}
+
+	clock.advance(testPolicy.LockoutFor)
+	if wait := mustRetryAfter(t, l, "k"); wait != 0 {
+		t.Errorf("Expected lockout to have ended, got wait %v", wait)
+	}
+	mustFail(t, l, "k")
+	if wait := mustRetryAfter(t, l, "k"); wait != 0 {
+		t.Errorf("Expected free attempts after lockout ended, got wait %v", wait)
+	}
+}
+
+func TestSucceedAndWindowReset(t *testing.T) {
+	l, clock := newTestLimiter()
+	for range testPolicy.FreeAttempts {
+		mustFail(t, l, "k")
+	}
+	if wait := mustRetryAfter(t, l, "k"); wait == 0 {
+		t.Fatalf("Expected backoff")
+	}
+	if err := l.Succeed(context.Background(), "k"); err != nil {
+		t.Fatalf("Error resetting key: %v", err)
+	}
+	if wait := mustRetryAfter(t, l, "k"); wait != 0 {
+		t.Errorf("Expected success to reset backoff, got wait %v", wait)
+	}
+
+	for range testPolicy.FreeAttempts {
+		mustFail(t, l, "k")
+	}
+	clock.advance(testPolicy.Window + time.Second)
+	s, _, err := l.Fail(context.Background(), "k")
+	if err != nil {
+		t.Fatalf("Error recording failure: %v", err)
+	}
+	if s.Failures != 1 {
+		t.Errorf("Expected old failures to be forgotten, got %d failures", s.Failures)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)
+	m := NewMemoryStore(time.Hour)
+	states := map[string]State{
+		"stale":  {Failures: 2, LastFailure: now.Add(-2 * time.Hour)},
+		"locked": {Failures: 8, LastFailure: now.Add(-2 * time.Hour), LockedUntil: now.Add(time.Minute)},
+		"recent": {Failures: 1, LastFailure: now.Add(-time.Minute)},
+	}
+	for key, s := range states {
+		if _, err := m.Update(context.Background(), key, func(State) State { return s }); err != nil {
+			t.Fatalf("Error storing %s: %v", key, err)
+		}
+	}
+	m.sweep(now)
+	for key := range states {
+		_, kept := m.states[key]
+		if want := key != "stale"; kept != want {
+			t.Errorf("after sweeping, %s kept = %v, want %v", key, kept, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldSecret = []byte("old-secret")
+	newSecret = []byte("new-secret")
+	testBody  = []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"123e4567-e89b-12d3-a456-426614174000"}}`)
+	testNow   = time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)
+)
+
+func newTestVerifier(secrets ...[]byte) *Verifier {
+	v := NewVerifier(DefaultTolerance, secrets...)
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	v := newTestVerifier(oldSecret, newSecret)
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"old secret", Sign(oldSecret, testNow, testBody), testBody, nil},
+		{"new secret", Sign(newSecret, testNow, testBody), testBody, nil},
+		{"within tolerance", Sign(newSecret, testNow.Add(-4*time.Minute), testBody), testBody, nil},
+		{"clock skew", Sign(newSecret, testNow.Add(4*time.Minute), testBody), testBody, nil},
+		{"too old", Sign(newSecret, testNow.Add(-6*time.Minute), testBody), testBody, ErrTimestamp},
+		{"too new", Sign(newSecret, testNow.Add(6*time.Minute), testBody), testBody, ErrTimestamp},
+		{"unknown secret", Sign([]byte("other"), testNow, testBody), testBody, ErrBadSignature},
+		{"tampered body", Sign(newSecret, testNow, testBody), []byte(`{"id":"evt_2"}`), ErrBadSignature},
+		{"missing", "", testBody, ErrNoSignature},
+		{"no timestamp", "v1=00", testBody, ErrMalformed},
+		{"no v1", "t=1760788800", testBody, ErrMalformed},
+		{"bad hex", "t=1760788800,v1=zz", testBody, ErrMalformed},
+		{"no equals sign", "t=1760788800,v1", testBody, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(tt.header, tt.body); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRotation(t *testing.T) {
+	// During rotation the sender signs with both secrets; a receiver that
+	// only knows one of them must still accept the delivery.
+	old := Sign(oldSecret, testNow, testBody)
+	header := old + ",v1=" + strings.SplitN(Sign(newSecret, testNow, testBody), "v1=", 2)[1]
+	for _, secret := range [][]byte{oldSecret, newSecret} {
+		if err := newTestVerifier(secret).Verify(header, testBody); err != nil {
+			t.Errorf("Verify() with %q = %v, want nil", secret, err)
+		}
+	}
+}
+
+func TestVerifyIgnoresUnknownSchemes(t *testing.T) {
+	header := Sign(newSecret, testNow, testBody) + ",v0=abc"
+	if err := newTestVerifier(newSecret).Verify(header, testBody); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+}
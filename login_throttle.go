@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/throttle"
+	"github.com/google/uuid"
+)
+
+// Failed logins are counted per account and per client IP. The IP limits
+// are looser because many users can share an address.
+var (
+	loginAccountPolicy = throttle.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockoutAfter: 10,
+		LockoutFor:   15 * time.Minute,
+		Window:       time.Hour,
+	}
+	loginIPPolicy = throttle.Policy{
+		FreeAttempts: 20,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockoutAfter: 100,
+		LockoutFor:   time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+type loginThrottle struct {
+	accounts *throttle.Limiter
+	ips      *throttle.Limiter
+}
+
+func newLoginThrottle(store throttle.Store) *loginThrottle {
+	return &loginThrottle{
+		accounts: throttle.NewLimiter(store, loginAccountPolicy),
+		ips:      throttle.NewLimiter(store, loginIPPolicy),
+	}
+}
+
+type throttleKey struct {
+	scope   string
+	key     string
+	limiter *throttle.Limiter
+}
+
+// keys are derived from the email that was typed in rather than the user it
+// belongs to, so unknown emails are throttled exactly like known ones.
+func (lt *loginThrottle) keys(r *http.Request, email string) []throttleKey {
+	return []throttleKey{
+		{"account", "login:account:" + strings.ToLower(strings.TrimSpace(email)), lt.accounts},
+		{"ip", "login:ip:" + getClientIP(r), lt.ips},
+	}
+}
+
+// checkLoginThrottle answers 429 and returns false if the account or the
+// client has to wait before trying to log in again.
+func (cfg *apiConfig) checkLoginThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	return checkThrottle(w, cfg.loginThrottle.keys(r, email), "Too many failed login attempts")
+}
+
+// checkThrottle answers 429 with message and returns false if any of keys
+// has to wait before its next attempt.
+func checkThrottle(w http.ResponseWriter, keys []throttleKey, message string) bool {
+	var wait time.Duration
+	for _, k := range keys {
+		d, err := k.limiter.RetryAfter(context.Background(), k.key)
+		if err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return false
+		}
+		wait = max(wait, d)
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		sendErrorResponse(w, http.StatusTooManyRequests, message)
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a wrong password or 2FA code, and records an
+// audit event when that locks the account or the client out. Errors are
+// only logged: the caller is already answering with a failure.
+func (cfg *apiConfig) recordLoginFailure(r *http.Request, email string, userID uuid.NullUUID) {
+	for _, k := range cfg.loginThrottle.keys(r, email) {
+		state, locked, err := k.limiter.Fail(context.Background(), k.key)
+		if err != nil {
+			log.Printf("login throttle: %v", err)
+			continue
+		}
+		if !locked {
+			continue
+		}
+		details := map[string]any{
+			"scope":        k.scope,
+			"email":        email,
+			"failures":     state.Failures,
+			"locked_until": state.LockedUntil,
+		}
+		if err := recordAuditEvent(context.Background(), cfg.db, auditLoginLockout, userID, getClientIP(r), details); err != nil {
+			log.Printf("login throttle: recording lockout: %v", err)
+		}
+	}
+}
+
+// recordLoginSuccess clears the account's failures. The client's are kept,
+// so logging into one account doesn't reset guesses against others.
+func (cfg *apiConfig) recordLoginSuccess(r *http.Request, email string) {
+	k := cfg.loginThrottle.keys(r, email)[0]
+	if err := k.limiter.Succeed(context.Background(), k.key); err != nil {
+		log.Printf("login throttle: %v", err)
+	}
+}
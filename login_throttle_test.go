@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	cfg := newTestConfigWithDB(t)
+	user := createTestUser(t, cfg, "user@example.com", roleUser)
+	loginTestUser(t, cfg, user, "test")
+	const password = "correct horse battery staple"
+	login := func(email, password string) *httptest.ResponseRecorder {
+		t.Helper()
+		return serve(t, cfg.handleLogin, "POST", "/api/login", "", map[string]string{"email": email, "password": password})
+	}
+	fail := func(email string, n int) {
+		t.Helper()
+		for i := range n {
+			if rec := login(email, "wrong"); rec.Code != http.StatusUnauthorized {
+				t.Fatalf("wrong password %d for %s = %d, want 401", i+1, email, rec.Code)
+			}
+		}
+	}
+
+	// A successful login clears the account's failures, so the next ones
+	// are free again.
+	fail(user.Email, loginAccountPolicy.FreeAttempts-1)
+	if rec := login(user.Email, password); rec.Code != http.StatusOK {
+		t.Fatalf("login = %d %s, want 200", rec.Code, rec.Body)
+	}
+	fail(user.Email, loginAccountPolicy.FreeAttempts)
+	rec := login(user.Email, password)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("correct password while throttled = %d, Retry-After %q, want 429 and a delay", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Unknown emails are throttled exactly like known ones.
+	fail("nobody@example.com", loginAccountPolicy.FreeAttempts)
+	if rec := login(" NOBODY@example.com", "wrong"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unknown email after %d failures = %d, want 429", loginAccountPolicy.FreeAttempts, rec.Code)
+	}
+}
@@ -1,23 +1,54 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/aleksaelezovic/chirpy/internal/auth"
 	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/aleksaelezovic/chirpy/internal/mail"
+	"github.com/aleksaelezovic/chirpy/internal/moderation"
+	"github.com/aleksaelezovic/chirpy/internal/throttle"
+	"github.com/aleksaelezovic/chirpy/internal/webhook"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
+const (
+	accessTokenTTL  = 1 * time.Hour
+	refreshTokenTTL = 60 * 24 * time.Hour
+
+	twoFactorChallengeTTL = 5 * time.Minute
+	passwordResetTTL      = 1 * time.Hour
+	emailVerificationTTL  = 24 * time.Hour
+)
+
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
-	isDev          bool
-	jwtSecret      string
+	dbConn         *sql.DB
+	jwtKeys        *auth.KeyRing
 	polkaApiKey    string
+	polkaVerifier  *webhook.Verifier
+	mailer         mail.Mailer
+	publicURL      string
+	loginThrottle  *loginThrottle
+	resetThrottle  *resetThrottle
+	moderator      *moderation.Moderator
+	// background tracks work that carries on after the response, such as
+	// sending mail.
+	background sync.WaitGroup
+	// requireVerifiedEmail stops accounts that haven't verified their email
+	// address from posting.
+	requireVerifiedEmail bool
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -27,6 +58,128 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// loadKeyRing builds the JWT key ring from JWT_KEYS_DIR and JWT_SECRET. New
+// tokens are always signed with the key named by JWT_SIGNING_KEY_ID. The
+// secret's key has an empty ID, so leaving JWT_SIGNING_KEY_ID unset signs
+// with HS256 as before. Every other loaded key, the secret included, still
+// verifies tokens, so tokens issued before a switch stay valid until they
+// expire.
+//
+// JWT_ISSUER, JWT_AUDIENCE, JWT_ALGORITHMS and JWT_LEEWAY tighten which
+// tokens are accepted.
+func loadKeyRing() (*auth.KeyRing, error) {
+	opts := auth.ValidatorOptions{
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+	for _, alg := range strings.Split(os.Getenv("JWT_ALGORITHMS"), ",") {
+		if alg = strings.TrimSpace(alg); alg != "" {
+			opts.Algorithms = append(opts.Algorithms, alg)
+		}
+	}
+	if leeway := os.Getenv("JWT_LEEWAY"); leeway != "" {
+		d, err := time.ParseDuration(leeway)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_LEEWAY: %w", err)
+		}
+		opts.Leeway = d
+	}
+
+	var keys []*auth.Key
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		keys = append(keys, auth.NewHMACKey("", []byte(secret)))
+	}
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		loaded, err := auth.LoadKeys(dir)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, loaded...)
+	}
+	return auth.NewKeyRing(opts, os.Getenv("JWT_SIGNING_KEY_ID"), keys...)
+}
+
+// loadPolkaVerifier returns a verifier for the comma-separated secrets in
+// POLKA_WEBHOOK_SECRETS, or nil when none are set. Listing both the old and
+// the new secret keeps webhooks working while Polka switches between them.
+// POLKA_WEBHOOK_TOLERANCE overrides how old a signature may be (default 5m).
+func loadPolkaVerifier() (*webhook.Verifier, error) {
+	var secrets [][]byte
+	for _, s := range strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			secrets = append(secrets, []byte(s))
+		}
+	}
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+	tolerance := webhook.DefaultTolerance
+	if s := os.Getenv("POLKA_WEBHOOK_TOLERANCE"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("POLKA_WEBHOOK_TOLERANCE: invalid duration %q", s)
+		}
+		tolerance = d
+	}
+	return webhook.NewVerifier(tolerance, secrets...), nil
+}
+
+// loadMailer sends mail through SMTP_ADDR when it is set. Otherwise mail is
+// written to MAIL_LOG_FILE, or to stdout, for development.
+func loadMailer() (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@localhost>"
+	}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return &mail.SMTPMailer{
+			Addr:     addr,
+			From:     from,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}, nil
+	}
+	if path := os.Getenv("MAIL_LOG_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return mail.NewLogMailer(from, f), nil
+	}
+	return mail.NewLogMailer(from, os.Stdout), nil
+}
+
+// loadModerator reads banned terms from MODERATION_TERMS_FILE, or from the
+// moderation_terms table when MODERATION_SOURCE=postgres. Either list is
+// reloaded every MODERATION_RELOAD_INTERVAL (default 1m). Without either,
+// moderation.DefaultTerms are masked.
+func loadModerator(db *sql.DB) (*moderation.Moderator, error) {
+	var source moderation.Source = moderation.StaticSource(moderation.DefaultTerms)
+	if path := os.Getenv("MODERATION_TERMS_FILE"); path != "" {
+		source = moderation.FileSource{Path: path}
+	} else if os.Getenv("MODERATION_SOURCE") == "postgres" {
+		source = moderation.NewPostgresSource(db)
+	}
+	moderator, err := moderation.NewModerator(context.Background(), source)
+	if err != nil {
+		return nil, err
+	}
+	if _, static := source.(moderation.StaticSource); static {
+		return moderator, nil
+	}
+	interval := time.Minute
+	if s := os.Getenv("MODERATION_RELOAD_INTERVAL"); s != "" {
+		interval, err = time.ParseDuration(s)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("MODERATION_RELOAD_INTERVAL: invalid duration %q", s)
+		}
+	}
+	go moderator.Watch(context.Background(), interval, func(err error) {
+		log.Printf("moderation: reloading terms: %v", err)
+	})
+	return moderator, nil
+}
+
 func main() {
 	godotenv.Load()
 	db, err := sql.Open("postgres", os.Getenv("DB_URL"))
@@ -35,11 +188,52 @@ func main() {
 		os.Exit(1)
 	}
 	defer db.Close()
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(db, os.Args[1:]))
+	}
+	jwtKeys, err := loadKeyRing()
+	if err != nil {
+		fmt.Printf("Error loading JWT keys: %v\n", err)
+		os.Exit(1)
+	}
+	polkaVerifier, err := loadPolkaVerifier()
+	if err != nil {
+		fmt.Printf("Error loading Polka webhook secrets: %v\n", err)
+		os.Exit(1)
+	}
+	mailer, err := loadMailer()
+	if err != nil {
+		fmt.Printf("Error setting up mail: %v\n", err)
+		os.Exit(1)
+	}
+	moderator, err := loadModerator(db)
+	if err != nil {
+		fmt.Printf("Error loading moderation terms: %v\n", err)
+		os.Exit(1)
+	}
+	// Failed logins and reset requests are counted in memory unless several
+	// instances need to share the counts.
+	var throttleStore throttle.Store = throttle.NewMemoryStore(time.Hour)
+	if os.Getenv("LOGIN_THROTTLE_STORE") == "postgres" {
+		throttleStore = throttle.NewPostgresStore(db, time.Hour)
+	}
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = "http://localhost:8080"
+	}
 	cfg := &apiConfig{
-		db:          database.New(db),
-		isDev:       os.Getenv("PLATFORM") == "dev",
-		jwtSecret:   os.Getenv("JWT_SECRET"),
-		polkaApiKey: os.Getenv("POLKA_KEY"),
+		db:            database.New(db),
+		dbConn:        db,
+		jwtKeys:       jwtKeys,
+		polkaApiKey:   os.Getenv("POLKA_KEY"),
+		polkaVerifier: polkaVerifier,
+		mailer:        mailer,
+		publicURL:     publicURL,
+		loginThrottle: newLoginThrottle(throttleStore),
+		resetThrottle: newResetThrottle(throttleStore),
+		moderator:     moderator,
+
+		requireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
 	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -49,17 +243,63 @@ func main() {
 	})
 	fsHandler := http.StripPrefix("/app", http.FileServer(http.Dir("./public")))
 	mux.Handle("/app/", cfg.middlewareMetricsInc(fsHandler))
-	mux.HandleFunc("GET /admin/metrics", cfg.metricsHandler)
-	mux.HandleFunc("POST /admin/reset", cfg.resetHandler)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.handleGetJWKS)
+	mux.HandleFunc("GET /admin/metrics", cfg.requireRole(roleAdmin, cfg.metricsHandler))
+	mux.HandleFunc("POST /admin/reset", cfg.requireRole(roleAdmin, cfg.resetHandler))
+	mux.HandleFunc("PUT /admin/users/{id}/role", cfg.requireRole(roleAdmin, cfg.handleSetUserRole))
+	mux.HandleFunc("PUT /admin/users/{id}/state", cfg.requireRole(roleAdmin, cfg.handleSetAccountState))
+	mux.HandleFunc("POST /admin/moderation/reload", cfg.requireRole(roleAdmin, cfg.handleReloadModerationTerms))
+	mux.HandleFunc("GET /admin/moderation/flags", cfg.requireRole(roleModerator, cfg.handleGetFlaggedChirps))
+	mux.HandleFunc("GET /admin/reports", cfg.requireRole(roleModerator, cfg.handleGetOpenReports))
+	mux.HandleFunc("POST /admin/reports/{id}/dismiss", cfg.requireRole(roleModerator, cfg.handleDismissReport))
+	mux.HandleFunc("POST /admin/reports/{id}/action", cfg.requireRole(roleModerator, cfg.handleActOnReport))
 	mux.HandleFunc("GET /api/chirps", cfg.handleGetChirps)
+	mux.HandleFunc("GET /api/chirps/search", cfg.handleSearchChirps)
 	mux.HandleFunc("GET /api/chirps/{id}", cfg.handleGetChirpByID)
+	mux.HandleFunc("GET /api/chirps/{id}/history", cfg.handleGetChirpHistory)
+	mux.HandleFunc("GET /api/chirps/{id}/thread", cfg.handleGetChirpThread)
 	mux.HandleFunc("POST /api/chirps", cfg.handleCreateChirp)
+	mux.HandleFunc("PUT /api/chirps/{id}", cfg.handleUpdateChirp)
 	mux.HandleFunc("DELETE /api/chirps/{id}", cfg.handleDeleteChirp)
+	mux.HandleFunc("POST /api/chirps/{id}/like", cfg.handleLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{id}/like", cfg.handleUnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{id}/rechirp", cfg.handleRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{id}/rechirp", cfg.handleUndoRechirp)
+	mux.HandleFunc("POST /api/chirps/{id}/report", cfg.handleReportChirp)
 	mux.HandleFunc("POST /api/users", cfg.handleCreateUser)
 	mux.HandleFunc("PUT /api/users", cfg.handleUpdateCredentials)
+	mux.HandleFunc("GET /api/users/{id}", cfg.handleGetUserProfile)
+	mux.HandleFunc("GET /api/users/{id}/{relation}", cfg.handleGetUserRelation)
+	mux.HandleFunc("POST /api/users/{id}/follow", cfg.handleFollowUser)
+	mux.HandleFunc("DELETE /api/users/{id}/follow", cfg.handleUnfollowUser)
+	mux.HandleFunc("POST /api/users/{id}/block", cfg.handleBlockUser)
+	mux.HandleFunc("DELETE /api/users/{id}/block", cfg.handleUnblockUser)
+	mux.HandleFunc("POST /api/users/{id}/mute", cfg.handleMuteUser)
+	mux.HandleFunc("DELETE /api/users/{id}/mute", cfg.handleUnmuteUser)
+	mux.HandleFunc("GET /api/blocks", cfg.handleGetBlocks)
+	mux.HandleFunc("GET /api/mutes", cfg.handleGetMutes)
+	mux.HandleFunc("GET /api/timeline", cfg.handleGetTimeline)
+	mux.HandleFunc("GET /api/hashtags/trending", cfg.handleGetTrendingHashtags)
+	mux.HandleFunc("GET /api/notifications", cfg.handleGetNotifications)
+	mux.HandleFunc("POST /api/notifications/read", cfg.handleMarkNotificationsRead)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.handleGetHashtagChirps)
 	mux.HandleFunc("POST /api/login", cfg.handleLogin)
 	mux.HandleFunc("POST /api/refresh", cfg.handleRefreshToken)
 	mux.HandleFunc("POST /api/revoke", cfg.handleRevokeRefreshToken)
+	mux.HandleFunc("POST /api/login/2fa", cfg.handleLoginTwoFactor)
+	mux.HandleFunc("POST /api/password-reset/request", cfg.handleRequestPasswordReset)
+	mux.HandleFunc("POST /api/password-reset/confirm", cfg.handleConfirmPasswordReset)
+	mux.HandleFunc("POST /api/email/verify", cfg.handleVerifyEmail)
+	mux.HandleFunc("POST /api/email/resend", cfg.handleResendEmailVerification)
+	mux.HandleFunc("POST /api/2fa/enroll", cfg.handleEnrollTOTP)
+	mux.HandleFunc("POST /api/2fa/confirm", cfg.handleConfirmTOTP)
+	mux.HandleFunc("POST /api/2fa/disable", cfg.handleDisableTOTP)
+	mux.HandleFunc("GET /api/sessions", cfg.handleGetSessions)
+	mux.HandleFunc("DELETE /api/sessions/{id}", cfg.handleRevokeSession)
+	mux.HandleFunc("POST /api/sessions/revoke-all", cfg.handleRevokeAllSessions)
+	mux.HandleFunc("POST /api/tokens", cfg.handleCreatePersonalAccessToken)
+	mux.HandleFunc("GET /api/tokens", cfg.handleGetPersonalAccessTokens)
+	mux.HandleFunc("DELETE /api/tokens/{id}", cfg.handleRevokePersonalAccessToken)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlePolkaWebhook)
 
 	server := http.Server{
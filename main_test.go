@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLoadKeyRingTrimsAlgorithms(t *testing.T) {
+	for _, env := range []string{"JWT_KEYS_DIR", "JWT_SIGNING_KEY_ID", "JWT_ISSUER", "JWT_AUDIENCE", "JWT_LEEWAY"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ALGORITHMS", " EdDSA, ,HS256 ")
+
+	keys, err := loadKeyRing()
+	if err != nil {
+		t.Fatalf("loadKeyRing() error = %v", err)
+	}
+	userID := uuid.New()
+	token, err := keys.MakeJWT(userID, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+	if got, err := keys.ValidateJWT(token); err != nil || got != userID {
+		t.Errorf("ValidateJWT() = %v, %v, want %v", got, err, userID)
+	}
+}
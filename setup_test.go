@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aleksaelezovic/chirpy/internal/auth"
+	"github.com/aleksaelezovic/chirpy/internal/database"
+	"github.com/aleksaelezovic/chirpy/internal/mail"
+	"github.com/aleksaelezovic/chirpy/internal/moderation"
+	"github.com/aleksaelezovic/chirpy/internal/throttle"
+	"github.com/google/uuid"
+)
+
+// testDBEnv names a scratch Postgres database for the handler tests. Its
+// public schema is dropped and rebuilt from sql/schema by every test that
+// uses it, so never point it at a database you care about. Tests that need
+// a database are skipped when it is unset.
+const testDBEnv = "CHIRPY_TEST_DB_URL"
+
+// newTestConfig returns a config without a database, for handlers that
+// answer before they query one.
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	keys, err := auth.NewKeyRing(auth.ValidatorOptions{}, "", auth.NewHMACKey("", []byte("test-secret")))
+	if err != nil {
+		t.Fatalf("Error creating key ring: %v", err)
+	}
+	moderator, err := moderation.NewModerator(context.Background(), moderation.StaticSource(moderation.DefaultTerms))
+	if err != nil {
+		t.Fatalf("Error creating moderator: %v", err)
+	}
+	return &apiConfig{
+		jwtKeys:       keys,
+		mailer:        mail.NewLogMailer("Chirpy <no-reply@localhost>", io.Discard),
+		publicURL:     "http://localhost:8080",
+		loginThrottle: newLoginThrottle(throttle.NewMemoryStore(time.Hour)),
+		resetThrottle: newResetThrottle(throttle.NewMemoryStore(time.Hour)),
+		moderator:     moderator,
+	}
+}
+
+// newTestConfigWithDB is newTestConfig backed by a freshly migrated
+// database.
+func newTestConfigWithDB(t *testing.T) *apiConfig {
+	t.Helper()
+	url := os.Getenv(testDBEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testDBEnv)
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrateTestDB(t, db)
+	cfg := newTestConfig(t)
+	cfg.db = database.New(db)
+	cfg.dbConn = db
+	return cfg
+}
+
+// migrateTestDB rebuilds the schema by running the up section of every
+// migration in order.
+func migrateTestDB(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if _, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		t.Fatalf("Error resetting schema: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join("sql", "schema", "*.sql"))
+	if err != nil {
+		t.Fatalf("Error listing migrations: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", file, err)
+		}
+		up, _, _ := strings.Cut(string(data), "-- +goose down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("Error applying %s: %v", file, err)
+		}
+	}
+}
+
+func createTestUser(t *testing.T, cfg *apiConfig, email, role string) database.User {
+	t.Helper()
+	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "unused",
+	})
+	if err == nil && role != roleUser {
+		user, err = cfg.db.SetUserRole(context.Background(), database.SetUserRoleParams{ID: user.ID, Role: role})
+	}
+	if err != nil {
+		t.Fatalf("Error creating user %s: %v", email, err)
+	}
+	return user
+}
+
+func createTestChirp(t *testing.T, cfg *apiConfig, user database.User, body string, inReplyTo uuid.NullUUID) database.Chirp {
+	t.Helper()
+	chirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
+		UserID:    user.ID,
+		Body:      body,
+		InReplyTo: inReplyTo,
+	})
+	if err != nil {
+		t.Fatalf("Error creating chirp: %v", err)
+	}
+	return chirp
+}
+
+// loginTestUser gives user a password and logs in with it, returning the
+// login response.
+func loginTestUser(t *testing.T, cfg *apiConfig, user database.User, userAgent string) loginResponse {
+	t.Helper()
+	const password = "correct horse battery staple"
+	hash, err := auth.HashPassword(password)
+	if err == nil {
+		_, err = cfg.db.UpdateUser(context.Background(), database.UpdateUserParams{
+			ID:             user.ID,
+			HashedPassword: sql.NullString{String: hash, Valid: true},
+		})
+	}
+	if err != nil {
+		t.Fatalf("Error setting password: %v", err)
+	}
+	data, err := json.Marshal(map[string]string{"email": user.Email, "password": password})
+	if err != nil {
+		t.Fatalf("Error encoding body: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/api/login", bytes.NewReader(data))
+	req.Header.Set("User-Agent", userAgent)
+	rec := httptest.NewRecorder()
+	cfg.handleLogin(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Error logging in as %s: %d %s", user.Email, rec.Code, rec.Body)
+	}
+	return decodeResponse[loginResponse](t, rec)
+}
+
+type loginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func makeTestToken(t *testing.T, cfg *apiConfig, user database.User) string {
+	t.Helper()
+	token, err := cfg.jwtKeys.MakeAccessJWT(auth.AccessClaims{UserID: user.ID, Role: user.Role}, time.Hour)
+	if err != nil {
+		t.Fatalf("Error making token: %v", err)
+	}
+	return token
+}
+
+// serve runs handler on a request with the given bearer token, JSON body
+// and path values, given as name/value pairs.
+func serve(t *testing.T, handler http.HandlerFunc, method, target, token string, body any, pathValues ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Error encoding body: %v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, reqBody)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		req.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func decodeResponse[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Error decoding response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+// recordingMailer keeps every message it is asked to send.
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// sent returns the messages sent so far, after waiting for background work.
+func (m *recordingMailer) sent(cfg *apiConfig) []mail.Message {
+	cfg.background.Wait()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.messages)
+}
+
+// tokenFromLink returns the token query parameter of the link in body.
+func tokenFromLink(t *testing.T, body string) string {
+	t.Helper()
+	_, link, ok := strings.Cut(body, "token=")
+	if !ok {
+		t.Fatalf("No link in %q", body)
+	}
+	token, _, _ := strings.Cut(link, "\n")
+	token, err := url.QueryUnescape(token)
+	if err != nil {
+		t.Fatalf("Error unescaping token: %v", err)
+	}
+	return token
+}
+
+func chirpIDs(chirps []chirpResponse) []uuid.UUID {
+	ids := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		ids[i] = chirp.ID
+	}
+	return ids
+}
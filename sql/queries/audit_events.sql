@@ -0,0 +1,3 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, type, user_id, ip, details)
+VALUES (gen_random_uuid(), $1, $2, $3, $4);
@@ -0,0 +1,54 @@
+-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlocks :many
+SELECT * FROM blocks
+WHERE blocker_id = sqlc.arg('user_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, blocked_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, blocked_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg('a') AND blocked_id = sqlc.arg('b'))
+       OR (blocker_id = sqlc.arg('b') AND blocked_id = sqlc.arg('a'))
+)::boolean;
+
+-- name: GetBlockingHandles :many
+-- Returns which of the given lower-cased handles belong to users who blocked
+-- user_id.
+SELECT lower(users.handle)::text FROM blocks
+JOIN users ON users.id = blocks.blocker_id
+WHERE blocks.blocked_id = sqlc.arg('user_id')
+  AND lower(users.handle) = ANY(sqlc.arg('handles')::text[]);
+
+-- name: MuteUser :exec
+INSERT INTO mutes (muter_id, muted_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutes :many
+SELECT * FROM mutes
+WHERE muter_id = sqlc.arg('user_id')
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, muted_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, muted_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: LockUserPair :many
+-- Locks both users in id order, so that a follow and a block between them
+-- can't interleave.
+SELECT id FROM users
+WHERE id IN (sqlc.arg('a')::uuid, sqlc.arg('b')::uuid)
+ORDER BY id
+FOR UPDATE;
@@ -0,0 +1,42 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes (user_id, chirp_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetLikedChirpIDs :many
+SELECT chirp_id FROM chirp_likes
+WHERE user_id = sqlc.arg('user_id') AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: GetChirpsLikedByUser :many
+SELECT sqlc.embed(chirps), chirp_likes.created_at AS liked_at
+FROM chirp_likes
+JOIN chirps ON chirps.id = chirp_likes.chirp_id
+WHERE chirp_likes.user_id = sqlc.arg('user_id')
+  AND chirps.deleted_at IS NULL
+  AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM sqlc.narg('viewer_id')::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = original.user_id)))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (chirp_likes.created_at, chirp_likes.chirp_id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY chirp_likes.created_at DESC, chirp_likes.chirp_id DESC
+LIMIT sqlc.arg('limit');
@@ -1,16 +1,261 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, user_id, body)
-VALUES (gen_random_uuid(), $1, $2)
+INSERT INTO chirps (id, user_id, body, in_reply_to, quote_of)
+VALUES (gen_random_uuid(), $1, $2, $3, $4)
 RETURNING *;
 
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, user_id, body, rechirp_of)
+VALUES (gen_random_uuid(), sqlc.arg('user_id'), '', sqlc.arg('rechirp_of')::uuid)
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: GetRechirp :one
+SELECT * FROM chirps WHERE user_id = sqlc.arg('user_id') AND rechirp_of = sqlc.arg('rechirp_of')::uuid;
+
+-- name: DeleteRechirp :exec
+DELETE FROM chirps WHERE user_id = sqlc.arg('user_id') AND rechirp_of = sqlc.arg('rechirp_of')::uuid;
+
+-- name: DeleteRechirpsOf :exec
+DELETE FROM chirps WHERE rechirp_of = sqlc.arg('rechirp_of')::uuid;
+
 -- name: GetAllChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM sqlc.narg('viewer_id')::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = original.user_id)))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: GetAllChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+  AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM sqlc.narg('viewer_id')::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = original.user_id)))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: GetChirpsByAuthor :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+SELECT * FROM chirps
+WHERE chirps.user_id = sqlc.arg('user_id')
+  AND deleted_at IS NULL
+  AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM sqlc.narg('viewer_id')::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = original.user_id)))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: GetChirpsByAuthorDesc :many
+SELECT * FROM chirps
+WHERE chirps.user_id = sqlc.arg('user_id')
+  AND deleted_at IS NULL
+  AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM sqlc.narg('viewer_id')::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = original.user_id)))
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: GetChirpByID :one
 SELECT * FROM chirps WHERE id = $1;
 
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[])
+  AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id);
+
+-- name: GetChirpByIDForUpdate :one
+SELECT * FROM chirps WHERE id = $1 FOR UPDATE;
+
+-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1 RETURNING *;
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps WHERE id = $1;
+
+-- name: TombstoneChirp :exec
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: IncrementLikeCount :exec
+UPDATE chirps SET like_count = like_count + 1 WHERE id = $1;
+
+-- name: DecrementLikeCount :exec
+UPDATE chirps SET like_count = like_count - 1 WHERE id = $1;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.in_reply_to, 1 AS depth
+    FROM chirps parent
+    JOIN chirps child ON child.in_reply_to = parent.id
+    WHERE child.id = sqlc.arg('id')::uuid
+  UNION ALL
+    SELECT parent.id, parent.in_reply_to, ancestors.depth + 1
+    FROM chirps parent
+    JOIN ancestors ON ancestors.in_reply_to = parent.id
+)
+SELECT chirps.* FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+WHERE (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpDescendants :many
+-- Replies the viewer may not see are left out together with the replies
+-- below them. Replies by muted users are left out on their own, because
+-- the replies below them can be from anyone.
+WITH RECURSIVE descendants AS (
+    SELECT chirps.id, 1 AS depth,
+        ARRAY[to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text] AS path
+    FROM chirps
+    WHERE chirps.in_reply_to = sqlc.arg('id')::uuid
+      AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+      AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+           OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  UNION ALL
+    SELECT chirps.id, descendants.depth + 1,
+        descendants.path || (to_char(chirps.created_at, 'YYYYMMDDHH24MISSUS') || chirps.id::text)
+    FROM chirps
+    JOIN descendants ON chirps.in_reply_to = descendants.id
+    WHERE (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+      AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+           OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+)
+SELECT sqlc.embed(chirps), descendants.depth::int AS depth
+FROM chirps
+JOIN descendants ON chirps.id = descendants.id
+WHERE NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+  AND (sqlc.narg('cursor_id')::uuid IS NULL
+   OR descendants.path > (SELECT path FROM descendants WHERE id = sqlc.narg('cursor_id')::uuid))
+ORDER BY descendants.path
+LIMIT sqlc.arg('limit');
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps), ts_rank(chirps.search_vector, query)::real AS rank
+FROM chirps, to_tsquery('english', sqlc.arg('query')) AS query
+WHERE chirps.search_vector @@ query
+  AND chirps.deleted_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR chirps.user_id = sqlc.narg('author_id')::uuid)
+  AND (chirps.user_id = sqlc.narg('viewer_id')::uuid OR NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.shadow_banned_at IS NOT NULL))
+  AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = chirps.user_id)
+       OR (blocks.blocker_id = chirps.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+  AND NOT EXISTS (
+    SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = chirps.user_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM chirps original
+    JOIN users ON users.id = original.user_id
+    WHERE original.id = COALESCE(chirps.rechirp_of, chirps.quote_of)
+      AND original.user_id IS DISTINCT FROM sqlc.narg('viewer_id')::uuid
+      AND (users.shadow_banned_at IS NOT NULL
+        OR EXISTS (
+          SELECT 1 FROM blocks
+          WHERE (blocks.blocker_id = sqlc.narg('viewer_id')::uuid AND blocks.blocked_id = original.user_id)
+             OR (blocks.blocker_id = original.user_id AND blocks.blocked_id = sqlc.narg('viewer_id')::uuid))
+        OR EXISTS (
+          SELECT 1 FROM mutes WHERE mutes.muter_id = sqlc.narg('viewer_id')::uuid AND mutes.muted_id = original.user_id)))
+  AND (sqlc.narg('cursor_rank')::real IS NULL
+   OR (ts_rank(chirps.search_vector, query), chirps.id) < (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_id')::uuid))
+ORDER BY rank DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
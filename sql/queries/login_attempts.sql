@@ -0,0 +1,22 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts WHERE key = $1;
+
+-- name: EnsureLoginAttempt :exec
+INSERT INTO login_attempts (key) VALUES ($1)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: GetLoginAttemptForUpdate :one
+SELECT * FROM login_attempts WHERE key = $1 FOR UPDATE;
+
+-- name: UpdateLoginAttempt :exec
+UPDATE login_attempts
+SET failures = $2, last_failure_at = $3, locked_until = $4
+WHERE key = $1;
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts WHERE key = $1;
+
+-- name: DeleteExpiredLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE (last_failure_at IS NULL OR last_failure_at < sqlc.arg('failed_before')::timestamp)
+  AND (locked_until IS NULL OR locked_until < sqlc.arg('now')::timestamp);
@@ -0,0 +1,20 @@
+-- name: ListModerationTerms :many
+SELECT * FROM moderation_terms ORDER BY term;
+
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, terms)
+VALUES ($1, $2)
+ON CONFLICT (chirp_id) DO UPDATE SET terms = EXCLUDED.terms, created_at = NOW();
+
+-- name: UnflagChirp :exec
+DELETE FROM chirp_flags WHERE chirp_id = $1;
+
+-- name: GetFlaggedChirps :many
+SELECT sqlc.embed(chirps), chirp_flags.terms, chirp_flags.created_at AS flagged_at
+FROM chirp_flags
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+WHERE chirps.deleted_at IS NULL
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (chirp_flags.created_at, chirp_flags.chirp_id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY chirp_flags.created_at, chirp_flags.chirp_id
+LIMIT sqlc.arg('limit');
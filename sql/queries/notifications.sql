@@ -0,0 +1,40 @@
+-- name: CreateNotification :exec
+-- Shadow-banned users don't know they are, so their actions still succeed
+-- but nobody is told about them. Undoing and redoing an action, such as
+-- following again after an unfollow, doesn't repeat a notification that is
+-- unread or less than a day old.
+INSERT INTO notifications (id, user_id, actor_id, type, chirp_id)
+SELECT gen_random_uuid(), sqlc.arg('user_id')::uuid, sqlc.arg('actor_id')::uuid, sqlc.arg('type')::text, sqlc.narg('chirp_id')::uuid
+WHERE NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = sqlc.arg('actor_id')::uuid AND users.shadow_banned_at IS NOT NULL)
+  AND NOT EXISTS (
+    SELECT 1 FROM notifications
+    WHERE notifications.user_id = sqlc.arg('user_id')::uuid
+      AND notifications.actor_id = sqlc.arg('actor_id')::uuid
+      AND notifications.type = sqlc.arg('type')::text
+      AND notifications.chirp_id IS NOT DISTINCT FROM sqlc.narg('chirp_id')::uuid
+      AND (notifications.read_at IS NULL OR notifications.created_at > NOW() - INTERVAL '1 day'));
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg('user_id')
+  AND (NOT sqlc.arg('unread_only')::boolean OR read_at IS NULL)
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg('user_id')
+  AND read_at IS NULL
+  AND id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
@@ -1,16 +1,44 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, user_id, expires_at)
-VALUES ($1, $2, $3)
+INSERT INTO refresh_tokens (token, user_id, expires_at, family_id, user_agent, ip)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
--- name: GetUserFromRefreshToken :one
-SELECT users.* FROM users JOIN refresh_tokens ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.revoked_at IS NULL
-  AND refresh_tokens.token = $1
-  AND refresh_tokens.expires_at > NOW()
-LIMIT 1;
+-- name: GetRefreshTokenForUpdate :one
+SELECT sqlc.embed(refresh_tokens), (refresh_tokens.expires_at <= NOW())::boolean AS expired
+FROM refresh_tokens
+WHERE refresh_tokens.token = $1
+FOR UPDATE;
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: GetSessions :many
+SELECT refresh_tokens.family_id AS id,
+    refresh_tokens.user_agent,
+    refresh_tokens.ip,
+    (SELECT MIN(family.created_at) FROM refresh_tokens family
+     WHERE family.family_id = refresh_tokens.family_id)::timestamp AS signed_in_at,
+    refresh_tokens.last_used_at,
+    refresh_tokens.expires_at
+FROM refresh_tokens
+WHERE refresh_tokens.user_id = $1
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > NOW()
+ORDER BY refresh_tokens.last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,27 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, chirp_id, chirp_author_id, chirp_body, reporter_id, reason, details)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: GetOpenReports :many
+SELECT * FROM reports
+WHERE status = 'open'
+  AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+   OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit');
+
+-- name: GetReportForUpdate :one
+SELECT * FROM reports WHERE id = $1 FOR UPDATE;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = $2, resolution = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW()
+WHERE id = $1 RETURNING *;
+
+-- name: ResolveChirpReports :exec
+-- Resolves the other open reports about a chirp along with the one acted on.
+UPDATE reports
+SET status = $2, resolution = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW()
+WHERE chirp_id = $1 AND status = 'open';
@@ -9,12 +9,80 @@ DELETE FROM users;
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1;
 
--- name: UpdateCredentials :one
+-- name: UpdateUser :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
-WHERE id = $1 RETURNING *;
+SET hashed_password = COALESCE(sqlc.narg('hashed_password'), hashed_password),
+    handle = COALESCE(sqlc.narg('handle'), handle),
+    display_name = COALESCE(sqlc.narg('display_name'), display_name),
+    bio = COALESCE(sqlc.narg('bio'), bio),
+    pending_email = COALESCE(sqlc.narg('pending_email'), pending_email),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') RETURNING *;
 
 -- name: ChangeChirpyRedStatus :one
 UPDATE users
 SET is_chirpy_red = $2, updated_at = NOW()
 WHERE id = $1 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUsersByHandles :many
+SELECT * FROM users WHERE lower(handle) = ANY(sqlc.arg('handles')::text[]);
+
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE lower(handle) = lower(sqlc.arg('handle'));
+
+-- name: ConfirmEmail :one
+UPDATE users
+SET email = $2, email_verified_at = NOW(), pending_email = NULL, updated_at = NOW()
+WHERE id = $1 RETURNING *;
+
+-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_last_step = 0, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled_at = NOW(), totp_last_step = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_last_step < $2;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1 RETURNING *;
+
+-- name: LockAdmins :many
+-- Locks every admin so that concurrent demotions can't remove the last one.
+SELECT id FROM users WHERE role = 'admin' FOR UPDATE;
+
+-- name: BootstrapAdmin :one
+-- Promotes a user to admin, but only while there are no admins at all.
+UPDATE users
+SET role = 'admin', updated_at = NOW()
+WHERE users.email = $1 AND NOT EXISTS (SELECT 1 FROM users AS admins WHERE admins.role = 'admin')
+RETURNING *;
+
+-- name: SuspendUser :one
+-- Never shortens a suspension that already lasts longer.
+UPDATE users
+SET suspended_until = GREATEST(suspended_until, sqlc.arg('suspended_until')::timestamp), updated_at = NOW()
+WHERE id = sqlc.arg('id') RETURNING *;
+
+-- name: SetAccountState :one
+UPDATE users
+SET suspended_until = sqlc.narg('suspended_until'),
+    shadow_banned_at = sqlc.narg('shadow_banned_at'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') RETURNING *;
@@ -0,0 +1,4 @@
+-- name: RecordWebhookEvent :execrows
+INSERT INTO webhook_events (id, event)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
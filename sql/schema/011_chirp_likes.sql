@@ -0,0 +1,13 @@
+-- +goose up
+CREATE TABLE chirp_likes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX chirp_likes_user_id_created_at_idx ON chirp_likes (user_id, created_at);
+ALTER TABLE chirps ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose down
+ALTER TABLE chirps DROP COLUMN like_count;
+DROP TABLE chirp_likes;
@@ -0,0 +1,12 @@
+-- +goose up
+ALTER TABLE users ADD COLUMN handle TEXT
+    CONSTRAINT users_handle_format CHECK (handle ~ '^[A-Za-z0-9_]{3,30}$');
+CREATE UNIQUE INDEX users_handle_idx ON users (lower(handle));
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+
+-- +goose down
+ALTER TABLE users DROP COLUMN bio;
+ALTER TABLE users DROP COLUMN display_name;
+DROP INDEX users_handle_idx;
+ALTER TABLE users DROP COLUMN handle;
@@ -0,0 +1,6 @@
+-- +goose up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +goose down
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,18 @@
+-- +goose up
+CREATE TABLE moderation_terms (
+    term TEXT PRIMARY KEY,
+    action TEXT NOT NULL DEFAULT 'mask' CHECK (action IN ('mask', 'flag', 'reject')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Chirps containing a term with the flag action, waiting for review.
+CREATE TABLE chirp_flags (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    terms TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX chirp_flags_created_at_idx ON chirp_flags (created_at, chirp_id);
+
+-- +goose down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_terms;
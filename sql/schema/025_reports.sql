@@ -0,0 +1,26 @@
+-- +goose up
+-- Reports copy the chirp they are about, so the evidence survives the chirp
+-- being deleted or hidden.
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    chirp_author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_body TEXT NOT NULL,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'dismissed', 'actioned')),
+    resolution TEXT CHECK (resolution IN ('hide_chirp', 'suspend_author')),
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP
+);
+CREATE UNIQUE INDEX reports_chirp_id_reporter_id_idx ON reports (chirp_id, reporter_id);
+CREATE INDEX reports_open_idx ON reports (created_at, id) WHERE status = 'open';
+
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+
+-- +goose down
+ALTER TABLE users DROP COLUMN suspended_until;
+DROP TABLE reports;
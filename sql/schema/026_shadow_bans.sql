@@ -0,0 +1,5 @@
+-- +goose up
+ALTER TABLE users ADD COLUMN shadow_banned_at TIMESTAMP;
+
+-- +goose down
+ALTER TABLE users DROP COLUMN shadow_banned_at;
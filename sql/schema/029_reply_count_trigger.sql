@@ -0,0 +1,35 @@
+-- +goose up
+-- Reply counts are kept by a trigger so that replies removed by a cascade,
+-- such as when their author is deleted, are counted too. A tombstone is only
+-- kept while it has replies, so removing its last reply removes it as well,
+-- which in turn updates its own parent.
+-- +goose StatementBegin
+CREATE FUNCTION chirps_count_replies() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.in_reply_to IS NOT NULL THEN
+            UPDATE chirps SET reply_count = reply_count + 1 WHERE id = NEW.in_reply_to;
+        END IF;
+    ELSIF OLD.in_reply_to IS NOT NULL THEN
+        UPDATE chirps SET reply_count = reply_count - 1 WHERE id = OLD.in_reply_to;
+        DELETE FROM chirps
+        WHERE id = OLD.in_reply_to AND deleted_at IS NOT NULL AND reply_count = 0;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_reply_count
+AFTER INSERT OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION chirps_count_replies();
+
+-- Repair counts and tombstones left behind by earlier cascades.
+UPDATE chirps SET reply_count = (
+    SELECT COUNT(*) FROM chirps replies WHERE replies.in_reply_to = chirps.id
+);
+DELETE FROM chirps WHERE deleted_at IS NOT NULL AND reply_count = 0;
+
+-- +goose down
+DROP TRIGGER chirps_reply_count ON chirps;
+DROP FUNCTION chirps_count_replies();
@@ -0,0 +1,5 @@
+-- +goose up
+CREATE INDEX follows_follower_id_idx ON follows (follower_id, created_at, followee_id);
+
+-- +goose down
+DROP INDEX follows_follower_id_idx;